@@ -0,0 +1,147 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Abbreviations indexes words by the segments they are made of, eg.
+// BuildCallStack by "build", "call" and "stack", to find the words an
+// abbreviation like BCS or bldCS stands for. Words are grouped by their first
+// letter, which an abbreviation always starts with.
+type Abbreviations struct {
+	words map[rune]map[string][][]rune // lower case segments of each word
+}
+
+// NewAbbreviations creates an empty Abbreviations index
+func NewAbbreviations() *Abbreviations {
+	return &Abbreviations{
+		words: make(map[rune]map[string][][]rune),
+	}
+}
+
+// AbbreviationsFromIndex builds the Abbreviations index of the words of the
+// given index
+func AbbreviationsFromIndex(idx *Index) *Abbreviations {
+	a := NewAbbreviations()
+	for word := range idx.references {
+		a.Insert(word)
+	}
+	return a
+}
+
+// Insert adds the word to the index
+func (a *Abbreviations) Insert(word string) {
+	parts := wordSegments(word)
+	if len(parts) == 0 {
+		return
+	}
+	first := parts[0][0]
+	if a.words[first] == nil {
+		a.words[first] = make(map[string][][]rune)
+	}
+	a.words[first][word] = parts
+}
+
+// Delete removes the word from the index
+func (a *Abbreviations) Delete(word string) {
+	parts := wordSegments(word)
+	if len(parts) == 0 {
+		return
+	}
+	first := parts[0][0]
+	delete(a.words[first], word)
+	if len(a.words[first]) == 0 {
+		delete(a.words, first)
+	}
+}
+
+// clone returns a copy of the index. The segments are never modified so they
+// are shared.
+func (a *Abbreviations) clone() *Abbreviations {
+	c := NewAbbreviations()
+	for first, words := range a.words {
+		c.words[first] = make(map[string][][]rune, len(words))
+		for word, parts := range words {
+			c.words[first][word] = parts
+		}
+	}
+	return c
+}
+
+// Find returns the words the abbreviation stands for, mapped to how many of
+// their segments it leaves out. Every letter of the abbreviation either starts
+// one of the next segments of the word or, if it is lower case, continues the
+// current segment, eg. bldCS is "b" starting Build, "l" and "d" within it, and
+// "C" and "S" starting Call and Stack. The first letter always starts the
+// first segment and segments may be skipped, so GFP matches GetFilePreview as
+// well as GetFullFilePreview.
+func (a *Abbreviations) Find(abbrev string) map[string]int {
+	query := []rune(abbrev)
+	matches := make(map[string]int)
+	if len(query) == 0 {
+		return matches
+	}
+
+	for word, parts := range a.words[unicode.ToLower(query[0])] {
+		if used, ok := matchSegments(query, parts, -1, 0); ok {
+			matches[word] = len(parts) - used
+		}
+	}
+	return matches
+}
+
+// matchSegments matches the query against the segments from the k-th one,
+// whose letters before p are used. Returns the fewest segments the query can
+// use to match.
+func matchSegments(query []rune, parts [][]rune, k, p int) (int, bool) {
+	if len(query) == 0 {
+		return 0, true
+	}
+	c := query[0]
+	lower := unicode.ToLower(c)
+
+	best, found := 0, false
+	if k >= 0 && c == lower {
+		for i := p; i < len(parts[k]); i++ {
+			if parts[k][i] != lower {
+				continue
+			}
+			if used, ok := matchSegments(query[1:], parts, k, i+1); ok {
+				best, found = used, true
+			}
+			break
+		}
+	}
+	for next := k + 1; next < len(parts); next++ {
+		if parts[next][0] != lower {
+			if k < 0 {
+				// the first letter must start the word
+				break
+			}
+			continue
+		}
+		if used, ok := matchSegments(query[1:], parts, next, 1); ok && (!found || used+1 < best) {
+			best, found = used+1, true
+		}
+		if k < 0 {
+			break
+		}
+	}
+	return best, found
+}
+
+// wordSegments splits a word into the lower case segments it is made of, at
+// underscores and where its case changes
+func wordSegments(word string) [][]rune {
+	var parts [][]rune
+	for _, field := range strings.Split(word, "_") {
+		if field == "" {
+			continue
+		}
+		for _, part := range camelParts(field) {
+			parts = append(parts, []rune(strings.ToLower(part)))
+		}
+	}
+	return parts
+}
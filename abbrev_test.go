@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAbbreviationsFind(t *testing.T) {
+	assert := assert.New(t)
+	a := NewAbbreviations()
+	for _, w := range []string{"BuildCallStack", "BuildCallStackJSON", "GetFilePreview", "getFile", "max_len", "Build"} {
+		a.Insert(w)
+	}
+
+	assert.Equal(map[string]int{"BuildCallStack": 0, "BuildCallStackJSON": 1}, a.Find("BCS"))
+	assert.Equal(map[string]int{"BuildCallStack": 0, "BuildCallStackJSON": 1}, a.Find("bldCS"))
+	assert.Equal(map[string]int{"GetFilePreview": 0}, a.Find("GFP"))
+	assert.Equal(map[string]int{"GetFilePreview": 1, "getFile": 0}, a.Find("gf"))
+	// segments can be skipped, but upper case letters must start one
+	assert.Equal(map[string]int{"GetFilePreview": 1}, a.Find("GP"))
+	assert.Equal(map[string]int{"GetFilePreview": 1}, a.Find("GeP"))
+	assert.Empty(a.Find("BUild"))
+	assert.Equal(map[string]int{"max_len": 0}, a.Find("ml"))
+	// and the first letter must start the word
+	assert.Empty(a.Find("CS"))
+
+	a.Delete("BuildCallStackJSON")
+	assert.Equal(map[string]int{"BuildCallStack": 0}, a.Find("BCS"))
+}
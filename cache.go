@@ -0,0 +1,344 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// IndexCacheVersion is bumped whenever the cache format or the way references
+// are indexed changes, so stale caches are ignored rather than misread
+const IndexCacheVersion = 7
+
+// IndexCache is the on-disk form of an Index. Every file is stored with the
+// hash of its contents and the references found in it, so a restart only has
+// to parse the files that changed since the cache was written. Type
+// information is not kept, but what the queries need of it is: the symbol each
+// reference resolved to, the receiver type of method calls, the method sets of
+// types and whether a variable is declared at package level. Symbols found
+// without it depend on where the declaration was found, which may be another
+// file, so those are looked up again after a restart.
+type IndexCache struct {
+	Version int                   `json:"version"`
+	Typed   bool                  `json:"typed"`
+	Files   map[string]*CacheFile `json:"files"` // keyed by relative path
+	Words   []string              `json:"words"` // contents of the Trie
+}
+
+// CacheFile holds what was indexed from a single file
+type CacheFile struct {
+	Hash       string            `json:"hash"`
+	Package    string            `json:"package"`
+	ImportPath string            `json:"import_path"`
+	Imports    []string          `json:"imports"`
+	References []*CacheReference `json:"references"`
+	Strings    []*StringLiteral  `json:"strings"`
+}
+
+// CacheReference is a Reference tagged with its kind so it can be decoded
+// into the right type again
+type CacheReference struct {
+	Kind   string          `json:"kind"`
+	Word   string          `json:"word"`
+	Symbol string          `json:"symbol,omitempty"`
+	Line   int             `json:"line"`
+	Column int             `json:"column"`
+	End    int             `json:"end"`
+	Data   json.RawMessage `json:"data"`
+	// found with type information only
+	Receiver string     `json:"receiver,omitempty"` // of a method call
+	Methods  signatures `json:"methods,omitempty"`  // of a type or interface
+	Global   bool       `json:"global,omitempty"`   // of a variable
+}
+
+// CachePath returns the path of the cache file for the project in root. Typed
+// and untyped indexes are cached separately.
+func CachePath(dir, root string, typed bool) string {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		abs = root
+	}
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s:%t", abs, typed)))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// BuildCachedIndex constructs the Index and Trie for the project, reusing what
+// it can from the cache at the given path and writing the cache back. Only
+// files whose contents changed are parsed again, and with type information
+// only their packages are type-checked, and every file of those packages is
+// indexed again. References in unchanged files that depend on other files, eg.
+// calls to a function that was since removed, are kept as they were.
+func BuildCachedIndex(fm *FileManager, typed bool, path string) (*Index, *Trie) {
+	cache := readIndexCache(path, typed)
+	idx := newIndex(fm)
+	idx.typed = typed
+
+	hashes := make(map[string]string, len(fm.files))
+	var changed []string
+	for _, file := range fm.files {
+		relPath := fm.Rel(file)
+		hash, err := fileHash(file)
+		if err != nil {
+			fmt.Printf("could not read %s: %v\n", file, err)
+			continue
+		}
+		hashes[relPath] = hash
+		if cached, ok := cache.Files[relPath]; !ok || cached.Hash != hash {
+			changed = append(changed, file)
+		}
+	}
+	if typed {
+		// the method sets of the types in a package may change with any of
+		// its files
+		changed = idx.packageFiles(changed)
+	}
+	reindex := make(map[string]bool, len(changed))
+	for _, file := range changed {
+		reindex[file] = true
+	}
+	for _, file := range fm.files {
+		relPath := fm.Rel(file)
+		if _, ok := hashes[relPath]; ok && !reindex[file] {
+			idx.restoreFile(relPath, cache.Files[relPath])
+		}
+	}
+	fmt.Printf("Reusing %d cached files, indexing %d\n", len(fm.files)-len(changed), len(changed))
+
+	if typed {
+		idx.indexTypedFiles(changed)
+	} else {
+		idx.indexFiles(changed)
+	}
+	idx.finish()
+
+	var trie *Trie
+	if len(changed) == 0 && len(hashes) == len(cache.Files) {
+		trie = NewTrie()
+		for _, word := range cache.Words {
+			trie.Insert(word)
+		}
+	} else {
+		trie = TrieFromIndex(idx)
+	}
+
+	if err := writeIndexCache(path, idx.cache(typed, hashes, trie)); err != nil {
+		fmt.Printf("Error writing index cache: %s\n", err)
+	}
+	return idx, trie
+}
+
+// readIndexCache reads the cache at the given path. An empty cache is returned
+// if there is none or it was written by another version or mode.
+func readIndexCache(path string, typed bool) *IndexCache {
+	empty := &IndexCache{Files: make(map[string]*CacheFile)}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return empty
+	}
+	cache := &IndexCache{}
+	if err := json.Unmarshal(data, cache); err != nil {
+		fmt.Printf("ignoring unreadable index cache %s: %v\n", path, err)
+		return empty
+	}
+	if cache.Version != IndexCacheVersion || cache.Typed != typed || cache.Files == nil {
+		return empty
+	}
+	return cache
+}
+
+func writeIndexCache(path string, cache *IndexCache) error {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// write to a temporary file first so an interrupted write can't leave a
+	// truncated cache behind
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func fileHash(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// cache returns the cache of the Index for files with the given hashes
+func (x *Index) cache(typed bool, hashes map[string]string, trie *Trie) *IndexCache {
+	cache := &IndexCache{
+		Version: IndexCacheVersion,
+		Typed:   typed,
+		Files:   make(map[string]*CacheFile, len(hashes)),
+		Words:   trie.Prefixes(),
+	}
+	sort.Strings(cache.Words)
+
+	for relPath, hash := range hashes {
+		f := &CacheFile{
+			Hash:    hash,
+			Imports: x.fileImports[relPath],
+			Strings: x.literals[relPath],
+		}
+		if pkg, ok := x.filePackages[relPath]; ok {
+			f.Package = pkg.Name
+			f.ImportPath = pkg.ImportPath
+		}
+		for _, span := range x.idents[relPath] {
+			data, err := span.ref.ToJSON()
+			if err != nil {
+				fmt.Printf("could not cache reference in %s: %v\n", relPath, err)
+				continue
+			}
+			cached := &CacheReference{
+				Kind:   referenceKind(span.ref),
+				Word:   referenceName(span.ref),
+				Symbol: x.objectSymbol(span.ref),
+				Line:   span.Line,
+				Column: span.Column,
+				End:    span.End,
+				Data:   data,
+			}
+			switch r := span.ref.(type) {
+			case *Function:
+				cached.Receiver = r.recvType
+			case *Variable:
+				cached.Global = r.global
+			case *Struct:
+				cached.Methods = r.methods
+			case *NamedType:
+				cached.Methods = r.methods
+			case *Interface:
+				cached.Methods = r.methods
+			}
+			f.References = append(f.References, cached)
+		}
+		cache.Files[relPath] = f
+	}
+	return cache
+}
+
+// restoreFile adds the package and references of a cached file to the Index
+func (x *Index) restoreFile(relPath string, f *CacheFile) {
+	if f.ImportPath != "" {
+		x.addPackageFile(relPath, f.Package, f.ImportPath, f.Imports)
+	}
+
+	for _, l := range f.Strings {
+		if l.Location != nil {
+			x.literals[relPath] = append(x.literals[relPath], l)
+		}
+	}
+
+	for _, cached := range f.References {
+		ref, err := decodeReference(cached)
+		if err != nil {
+			fmt.Printf("could not restore reference in %s: %v\n", relPath, err)
+			continue
+		}
+
+		switch r := ref.(type) {
+		case *Function:
+			if r.IsDecl {
+				x.functions[r.Name] = append(x.functions[r.Name], r)
+			}
+			r.recvType = cached.Receiver
+		case *Variable:
+			r.global = cached.Global
+		case *Struct:
+			x.structs[r.Name] = append(x.structs[r.Name], r)
+			r.methods = restoredMethods(cached)
+		case *Interface:
+			x.interfaces[r.Name] = append(x.interfaces[r.Name], r)
+			r.methods = restoredMethods(cached)
+		case *NamedType:
+			x.types[r.Name] = append(x.types[r.Name], r)
+			r.methods = restoredMethods(cached)
+		}
+
+		x.references[cached.Word] = append(x.references[cached.Word], ref)
+		x.idents[relPath] = append(x.idents[relPath], &identSpan{
+			Line:   cached.Line,
+			Column: cached.Column,
+			End:    cached.End,
+			ref:    ref,
+		})
+		if cached.Symbol != "" {
+			x.objectSymbols[ref] = cached.Symbol
+		}
+		x.addDoc(ref)
+	}
+}
+
+// restoredMethods returns the method set of a cached type. An empty method set
+// is left out of the cache, but a type found with type information, which is
+// when it has a symbol, still has one.
+func restoredMethods(cached *CacheReference) signatures {
+	if cached.Methods == nil && cached.Symbol != "" {
+		return signatures{}
+	}
+	return cached.Methods
+}
+
+// referenceKind names the type of the Reference in the cache
+func referenceKind(ref Reference) string {
+	switch ref.(type) {
+	case *Function:
+		return "function"
+	case *Variable:
+		return "variable"
+	case *Constant:
+		return "constant"
+	case *Struct:
+		return "struct"
+	case *Interface:
+		return "interface"
+	case *NamedType:
+		return "type"
+	case *Field:
+		return "field"
+	}
+	return ""
+}
+
+func decodeReference(cached *CacheReference) (Reference, error) {
+	var ref Reference
+	switch cached.Kind {
+	case "function":
+		ref = &Function{}
+	case "variable":
+		ref = &Variable{}
+	case "constant":
+		ref = &Constant{}
+	case "struct":
+		ref = &Struct{}
+	case "interface":
+		ref = &Interface{}
+	case "type":
+		ref = &NamedType{}
+	case "field":
+		ref = &Field{}
+	default:
+		return nil, fmt.Errorf("unknown reference kind %q", cached.Kind)
+	}
+	if err := json.Unmarshal(cached.Data, ref); err != nil {
+		return nil, err
+	}
+	if ref.GetLocation() == nil {
+		return nil, fmt.Errorf("%s %s has no location", cached.Kind, cached.Word)
+	}
+	return ref, nil
+}
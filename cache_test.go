@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// locations returns the file and line of every Reference, sorted
+func locations(refs []Reference) []string {
+	locs := []string{}
+	for _, ref := range refs {
+		loc := ref.GetLocation()
+		locs = append(locs, fmt.Sprintf("%s:%d", loc.File, loc.Line))
+	}
+	sort.Strings(locs)
+	return locs
+}
+
+func TestBuildCachedIndexEdits(t *testing.T) {
+	for _, typed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("typed=%t", typed), func(t *testing.T) {
+			testCachedIndexEdits(t, typed)
+		})
+	}
+}
+
+func testCachedIndexEdits(t *testing.T, typed bool) {
+	assert := assert.New(t)
+	root := writeProject(t, map[string]string{
+		"go.mod": "module example.com/proj\n",
+		"a.go":   "package main\n\n// Count counts\nfunc Count() int {\n\treturn 1\n}\n",
+		"b.go":   "package main\n\nfunc main() {\n\tn := Count()\n\t_ = n\n}\n",
+	})
+	defer os.RemoveAll(root)
+	cache := filepath.Join(root, "cache", "index.json")
+	edit := func(name, src string) {
+		assert.NoError(ioutil.WriteFile(filepath.Join(root, name), []byte(src), 0644))
+	}
+	build := func() *Index {
+		idx, _ := BuildCachedIndex(NewFileManager(root), typed, cache)
+		return idx
+	}
+	// where Count is declared and used once the cache is restored
+	check := func(idx *Index, decl string, call string, line, col int) {
+		ref, ok := idx.Definition("b.go", line, col)
+		if assert.True(ok) {
+			assert.Equal([]string{decl}, locations([]Reference{ref}))
+		}
+		id, ok := idx.SymbolAt("a.go", ref.GetLocation().Line, 0)
+		assert.True(ok)
+		refs, _ := idx.ReferencesBySymbol(id)
+		assert.Equal([]string{decl, call}, locations(refs))
+	}
+
+	build()
+	// the declaration moves, the call in b.go comes from the cache unless
+	// it is type-checked with it
+	edit("a.go", "package main\n\n// more\n\n// Count counts\nfunc Count() int {\n\treturn 1\n}\n")
+	check(build(), "a.go:6", "b.go:4", 4, 7)
+
+	// the call moves, the declaration comes from the cache
+	edit("b.go", "package main\n\n// main runs\nfunc main() {\n\tn := Count()\n\t_ = n\n}\n")
+	check(build(), "a.go:6", "b.go:5", 5, 7)
+}
+
+func TestBuildCachedIndexTyped(t *testing.T) {
+	assert := assert.New(t)
+	root := writeProject(t, map[string]string{
+		"go.mod": "module example.com/proj\n",
+		"main.go": `package main
+
+var total int
+
+type Getter interface {
+	Get() int
+}
+
+type Counter struct{}
+
+func (c *Counter) Get() int { return total }
+
+type Label struct{}
+
+func (l Label) Get() string { return "" }
+
+func main() {
+	c := &Counter{}
+	total = c.Get()
+}
+`,
+	})
+	defer os.RemoveAll(root)
+	cache := filepath.Join(root, "cache", "index.json")
+	BuildCachedIndex(NewFileManager(root), true, cache)
+
+	// every file is restored from the cache, without type information
+	idx, _ := BuildCachedIndex(NewFileManager(root), true, cache)
+	for _, ref := range idx.references["Get"] {
+		assert.Nil(ref.GetObject())
+	}
+	// but the signatures of the methods still count
+	assert.Equal([]string{"Counter"}, names(idx.Implementations("Getter")))
+	assert.Empty(names(idx.Satisfies("Label")))
+	// the call still has the type of its receiver
+	var calls []string
+	for _, ref := range idx.references["Get"] {
+		if fn := ref.(*Function); !fn.IsDecl {
+			calls = append(calls, receiverOf(fn))
+		}
+	}
+	assert.Equal([]string{"Counter"}, calls)
+	// and the uses of total are still of a package-level variable
+	for _, ref := range idx.references["total"] {
+		assert.True(ref.(*Variable).IsGlobal(), "%s", ref.GetLocation())
+	}
+}
@@ -58,28 +58,38 @@ func (f Functions) callStackHelper(fn *Function, cs *CallStack, seen []string, d
 	seen = append(seen, fn.Info())
 	cs.Name = fn.Info()
 	cs.Depth = depth
-	for _, fnName := range fn.Calls {
-		fnDefs, ok := f[fnName]
+	for _, call := range fn.calls {
+		fnDefs, ok := f[call.Name]
 		if !ok || len(fnDefs) == 0 {
 			// function is defined externally
 			cs.Children = append(cs.Children,
-				&CallStack{Name: fmt.Sprintf("%s (external)", fnName), Depth: depth + 1})
+				&CallStack{Name: launchName(call, fmt.Sprintf("%s (external)", call.Name)), Depth: depth + 1})
 			continue
 		}
 
 		childFn := fnDefs[0]
 		if alreadySeen(seen, childFn) {
 			// avoid loops
-			cs.Children = append(cs.Children, &CallStack{Name: childFn.Info(), Depth: depth + 1})
+			cs.Children = append(cs.Children, &CallStack{Name: launchName(call, childFn.Info()), Depth: depth + 1})
 			continue
 		}
 
 		childCS := &CallStack{}
 		f.callStackHelper(childFn, childCS, seen, depth+1)
+		childCS.Name = launchName(call, childCS.Name)
 		cs.Children = append(cs.Children, childCS)
 	}
 }
 
+// launchName prefixes the name of a node with the statement the call is made
+// by, if any, eg. "go worker (main.go:20)"
+func launchName(call *Function, name string) string {
+	if call.Launch == "" {
+		return name
+	}
+	return fmt.Sprintf("%s %s", call.Launch, name)
+}
+
 func alreadySeen(seen []string, fn *Function) bool {
 	for _, s := range seen {
 		if s == fn.Info() {
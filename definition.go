@@ -0,0 +1,161 @@
+package main
+
+// Definition resolves the identifier at the given line and column of a file to
+// the Reference that declares it. Columns are 1-based byte offsets, the same as
+// go/token. Returns false if there is no identifier at that position or its
+// declaration is not part of the project.
+func (x *Index) Definition(file string, line, col int) (Reference, bool) {
+	ref, ok := x.referenceAt(file, line, col)
+	if !ok {
+		return nil, false
+	}
+	return x.declarationOf(ref)
+}
+
+// declarationOf returns the Reference that declares the word used by ref
+func (x *Index) declarationOf(ref Reference) (Reference, bool) {
+	if isDeclaration(ref) {
+		return ref, true
+	}
+	return x.declarationIn(ref, x.declarationsOf(referenceName(ref)))
+}
+
+// declarations holds the declarations of a word, bucketed by symbol ID, by file
+// and by the function they are in, so the declaration of each reference to the
+// word is found without going through all of them
+type declarations struct {
+	all    []Reference
+	ids    map[string]Reference
+	files  map[string][]Reference
+	scopes map[string][]Reference
+}
+
+// declarationsOf collects the declarations of the word, in the order they are
+// indexed
+func (x *Index) declarationsOf(word string) *declarations {
+	decls := &declarations{
+		ids:    make(map[string]Reference),
+		files:  make(map[string][]Reference),
+		scopes: make(map[string][]Reference),
+	}
+	for _, c := range x.references[word] {
+		if !isDeclaration(c) {
+			continue
+		}
+		decls.all = append(decls.all, c)
+		if id := x.objectSymbol(c); id != "" {
+			if _, ok := decls.ids[id]; !ok {
+				decls.ids[id] = c
+			}
+		}
+		loc := c.GetLocation()
+		decls.files[loc.File] = append(decls.files[loc.File], c)
+		if loc.Within != "" {
+			decls.scopes[scopeKey(loc)] = append(decls.scopes[scopeKey(loc)], c)
+		}
+	}
+	return decls
+}
+
+// scopeKey identifies the function a Location is in
+func scopeKey(loc *Location) string {
+	return loc.File + "\x00" + loc.Within
+}
+
+// declarationIn returns the Reference among the declarations of its word that
+// declares the word used by ref
+func (x *Index) declarationIn(ref Reference, decls *declarations) (Reference, bool) {
+	if isDeclaration(ref) {
+		return ref, true
+	}
+
+	// with type information, the declaration is the one that defines the same
+	// symbol as the reference uses. Objects are compared by ID since packages
+	// loaded at different times, or restored from the cache, don't share them.
+	if id := x.objectSymbol(ref); id != "" {
+		decl, ok := decls.ids[id]
+		return decl, ok
+	}
+
+	// otherwise make a best guess by name, favoring a declaration earlier in
+	// the same function over one in the same file over anything else
+	loc := ref.GetLocation()
+	if loc.Within != "" {
+		for _, c := range decls.scopes[scopeKey(loc)] {
+			if c.GetLocation().Line <= loc.Line && sameKind(ref, c) {
+				return c, true
+			}
+		}
+	}
+	for _, candidates := range [][]Reference{decls.files[loc.File], decls.all} {
+		for _, c := range candidates {
+			if sameKind(ref, c) {
+				return c, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// referenceAt returns the Reference whose identifier covers the given position
+func (x *Index) referenceAt(file string, line, col int) (Reference, bool) {
+	for _, span := range x.idents[file] {
+		if span.Line == line && col >= span.Column && col < span.End {
+			return span.ref, true
+		}
+	}
+	return nil, false
+}
+
+// isDeclaration returns true if the Reference declares its word
+func isDeclaration(ref Reference) bool {
+	switch r := ref.(type) {
+	case *Function:
+		return r.IsDecl
+	case *Variable:
+		return r.IsDecl
+	case *Field:
+		return r.IsDecl
+	case *Constant:
+		return r.IsDecl
+	case *Struct, *Interface, *NamedType:
+		return true
+	}
+	return false
+}
+
+// referenceName returns the word the Reference was indexed under
+func referenceName(ref Reference) string {
+	switch r := ref.(type) {
+	case *Function:
+		return r.Name
+	case *Variable:
+		return r.Name
+	case *Field:
+		return r.Name
+	case *Struct:
+		return r.Name
+	case *Interface:
+		return r.Name
+	case *Constant:
+		return r.Name
+	case *NamedType:
+		return r.Name
+	}
+	return ""
+}
+
+// sameKind returns true if both references could name the same thing. Without
+// type information a variable may well refer to a type or a function value, so
+// only function calls and fields are held to declarations of their own kind.
+func sameKind(ref, decl Reference) bool {
+	switch r := ref.(type) {
+	case *Function:
+		_, ok := decl.(*Function)
+		return ok
+	case *Field:
+		d, ok := decl.(*Field)
+		return ok && (r.Struct == "" || r.Struct == d.Struct)
+	}
+	return true
+}
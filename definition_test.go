@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const definitionSource = `package main
+
+// Server serves
+type Server struct {
+	port int
+}
+
+func (s *Server) Listen() int {
+	port := 1
+	use(port, s.port)
+	return port
+}
+
+func main() {
+	port := 2
+	s := &Server{}
+	use(s.Listen(), port)
+	total := port
+	total = total + port
+}
+
+func use(args ...int) {}
+`
+
+func TestDefinition(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"go.mod":  "module example.com/proj\n",
+		"main.go": definitionSource,
+	})
+	defer os.RemoveAll(root)
+
+	fm := NewFileManager(root)
+	for _, typed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("typed=%t", typed), func(t *testing.T) {
+			assert := assert.New(t)
+			idx := BuildIndex(fm)
+			if typed {
+				idx = BuildTypedIndex(fm)
+			}
+			definition := func(line, col int) string {
+				ref, ok := idx.Definition("main.go", line, col)
+				if !ok {
+					return ""
+				}
+				return fmt.Sprintf("%s %s:%d", referenceKind(ref), ref.GetLocation().File, ref.GetLocation().Line)
+			}
+
+			assert.Equal("function main.go:8", definition(17, 8))
+			assert.Equal("function main.go:22", definition(17, 2))
+			// the port of main, not the one of Listen
+			assert.Equal("variable main.go:15", definition(17, 18))
+			assert.Equal("variable main.go:9", definition(10, 6))
+			assert.Equal("field main.go:5", definition(10, 14))
+			// plain uses in a return, an assignment and a binary expression
+			assert.Equal("variable main.go:9", definition(11, 9))
+			assert.Equal("variable main.go:18", definition(19, 2))
+			assert.Equal("variable main.go:18", definition(19, 10))
+			assert.Equal("variable main.go:15", definition(19, 18))
+			// declarations are their own definition
+			assert.Equal("function main.go:8", definition(8, 18))
+			assert.Equal("struct main.go:4", definition(4, 6))
+			// no identifier there
+			assert.Equal("", definition(2, 1))
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"go/ast"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// stopWords are left out of the doc index and doc queries, so a question like
+// "what handles the callstack" comes down to the words that matter
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "does": true, "for": true, "from": true,
+	"how": true, "if": true, "in": true, "is": true, "it": true, "of": true,
+	"on": true, "or": true, "the": true, "this": true, "that": true,
+	"to": true, "what": true, "when": true, "where": true, "which": true,
+	"who": true, "with": true,
+}
+
+// SearchDocs returns the declarations whose doc comments contain words of the
+// query. Every query word matches the doc words it is a prefix of, so
+// "handle" finds "handles" and "handler". Declarations matching more of the
+// query words rank first, then the ones matching them exactly.
+func (x *Index) SearchDocs(query string) []Reference {
+	matched := make(map[Reference]int)
+	scores := make(map[Reference]int)
+	for _, token := range docTokens(query) {
+		seen := make(map[Reference]bool)
+		for word, refs := range x.docs {
+			if !strings.HasPrefix(word, token) {
+				continue
+			}
+			for _, ref := range refs {
+				if !seen[ref] {
+					seen[ref] = true
+					matched[ref]++
+				}
+				if word == token {
+					scores[ref] += 2
+				} else {
+					scores[ref]++
+				}
+			}
+		}
+	}
+
+	results := make([]Reference, 0, len(matched))
+	for ref := range matched {
+		results = append(results, ref)
+	}
+	sort.Sort(sort.Reverse(SmartSort(results)))
+	sort.SliceStable(results, func(i, j int) bool {
+		if matched[results[i]] != matched[results[j]] {
+			return matched[results[i]] > matched[results[j]]
+		}
+		return scores[results[i]] > scores[results[j]]
+	})
+	return results
+}
+
+// docText returns the text of a doc comment
+func docText(doc *ast.CommentGroup) string {
+	return strings.TrimSpace(doc.Text())
+}
+
+// docOf returns the doc comment of a declaration, if it has one
+func docOf(ref Reference) string {
+	switch r := ref.(type) {
+	case *Function:
+		return r.Doc
+	case *Struct:
+		return r.Doc
+	case *Interface:
+		return r.Doc
+	case *NamedType:
+		return r.Doc
+	}
+	return ""
+}
+
+// addDoc indexes the words of the doc comment of the declaration
+func (x *Index) addDoc(ref Reference) {
+	for _, token := range docTokens(docOf(ref)) {
+		if !hasReference(x.docs[token], ref) {
+			x.docs[token] = append(x.docs[token], ref)
+		}
+	}
+}
+
+// removeDoc drops the declaration from the doc index
+func (x *Index) removeDoc(ref Reference) {
+	stale := map[Reference]bool{ref: true}
+	for _, token := range docTokens(docOf(ref)) {
+		if refs := withoutReferences(x.docs[token], stale); len(refs) > 0 {
+			x.docs[token] = refs
+		} else {
+			delete(x.docs, token)
+		}
+	}
+}
+
+// docTokens splits text into lower case words, leaving out stop words. Mixed
+// case identifiers are split into their parts as well, eg. BuildIndex gives
+// "buildindex", "build" and "index".
+func docTokens(text string) []string {
+	var tokens []string
+	add := func(token string) {
+		token = strings.ToLower(token)
+		if token != "" && !stopWords[token] && !hasString(tokens, token) {
+			tokens = append(tokens, token)
+		}
+	}
+
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, field := range fields {
+		add(field)
+		if parts := camelParts(field); len(parts) > 1 {
+			for _, part := range parts {
+				add(part)
+			}
+		}
+	}
+	return tokens
+}
+
+// camelParts splits an identifier into the words it is made of, eg.
+// "parseHTTPRequest" into "parse", "HTTP" and "Request"
+func camelParts(ident string) []string {
+	var parts []string
+	runes := []rune(ident)
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		lowerToUpper := unicode.IsLower(prev) && unicode.IsUpper(cur)
+		// the last upper case letter of an acronym starts the next word
+		acronymEnd := unicode.IsUpper(prev) && unicode.IsUpper(cur) &&
+			i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		digits := unicode.IsDigit(prev) != unicode.IsDigit(cur)
+		if lowerToUpper || acronymEnd || digits {
+			parts = append(parts, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(parts, string(runes[start:]))
+}
+
+func hasReference(refs []Reference, ref Reference) bool {
+	for _, r := range refs {
+		if r == ref {
+			return true
+		}
+	}
+	return false
+}
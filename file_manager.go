@@ -3,6 +3,8 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"go/build"
+	"html"
 	"os"
 	"path"
 	"path/filepath"
@@ -15,20 +17,33 @@ const PreviewLineOffset = 5
 
 // FileManager is a struct for managing files and file operations in the project
 type FileManager struct {
-	root  string
-	files []string // relative file paths
+	root    string
+	modPath string   // import path of the root directory
+	files   []string // relative file paths
 }
 
 // NewFileManager inits a FileManager from the given root. It digs into the root
 // directory to capture all .go filepaths for the project
 func NewFileManager(root string) *FileManager {
-	fm := &FileManager{root: root}
+	// go/packages reports absolute file paths, which are only relative to an
+	// absolute root
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	fm := &FileManager{root: root, modPath: modulePath(root)}
 	fm.findFiles()
 	return fm
 }
 
 func (m *FileManager) findFiles() {
 	m.files = []string{}
+	m.walkFiles(func(path string, info os.FileInfo) {
+		m.files = append(m.files, path)
+	})
+}
+
+// walkFiles calls visit for every .go file under the root directory
+func (m *FileManager) walkFiles(visit func(path string, info os.FileInfo)) {
 	filepath.Walk(m.root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			fmt.Printf("Failed to access path %q: %v\n", path, err)
@@ -36,11 +51,10 @@ func (m *FileManager) findFiles() {
 		}
 		// don't walk the vendor directory
 		if info.IsDir() && info.Name() == "vendor" {
-			fmt.Printf("skipping a dir without errors: %+v \n", info.Name())
 			return filepath.SkipDir
 		}
 		if !info.IsDir() && strings.HasSuffix(info.Name(), ".go") {
-			m.files = append(m.files, path)
+			visit(path, info)
 		}
 		return nil
 	})
@@ -52,22 +66,77 @@ func (m *FileManager) Rel(targpath string) string {
 	return relpath
 }
 
+// ImportPath returns the import path of the package in the given directory
+func (m *FileManager) ImportPath(dir string) string {
+	rel := filepath.ToSlash(m.Rel(dir))
+	if rel == "." {
+		return m.modPath
+	}
+	return path.Join(m.modPath, rel)
+}
+
+// modulePath reads the module path from the go.mod file in the root directory.
+// Without a go.mod it is the path of the root directory within the src
+// directory of a GOPATH workspace, or failing that the name of the root
+// directory.
+func modulePath(root string) string {
+	if f, err := os.Open(filepath.Join(root, "go.mod")); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if strings.HasPrefix(line, "module ") {
+				return strings.Trim(strings.TrimSpace(line[len("module "):]), "\"")
+			}
+		}
+	}
+
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return filepath.Base(root)
+	}
+	if p, ok := gopathImportPath(abs); ok {
+		return p
+	}
+	return filepath.Base(abs)
+}
+
+// gopathImportPath returns the import path of the directory if it is in the src
+// directory of one of the GOPATH workspaces
+func gopathImportPath(dir string) (string, bool) {
+	for _, gopath := range filepath.SplitList(build.Default.GOPATH) {
+		if gopath == "" {
+			continue
+		}
+		rel, err := filepath.Rel(filepath.Join(gopath, "src"), dir)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return filepath.ToSlash(rel), true
+	}
+	return "", false
+}
+
 // Preview is the response type for a code preview. It contains a formatted
-// string of the code snippet.
+// string of the code snippet and the values of the constants declared in it.
+// The code is HTML escaped, with the highlighted span wrapped in a mark tag.
 type Preview struct {
-	Code string `json:"code"`
+	Code      string      `json:"code"`
+	Constants []*Constant `json:"constants,omitempty"`
 }
 
 // GetFilePreview finds the file and reads the area around the requested line
-// number to generate a code Preview. Return an error if the file is not found
-// or an error is encountered while reading the file.
-func (m *FileManager) GetFilePreview(file string, line int) (*Preview, error) {
+// number to generate a code Preview. If a span is given, the code from its line
+// and column to its end line and column is highlighted. Return an error if the
+// file is not found or an error is encountered while reading the file.
+func (m *FileManager) GetFilePreview(file string, line int, span *Location) (*Preview, error) {
 	filepath := path.Join(m.root, file)
 
 	input, err := os.Open(filepath)
 	if err != nil {
 		return nil, err
 	}
+	defer input.Close()
 
 	start := line - PreviewLineOffset
 	if line < 0 {
@@ -80,7 +149,37 @@ func (m *FileManager) GetFilePreview(file string, line int) (*Preview, error) {
 		if pos < start {
 			continue
 		}
-		lines = append(lines, fmt.Sprintf("%d\t%s", pos+1, scanner.Text()))
+		lines = append(lines, fmt.Sprintf("%d\t%s", pos+1, highlight(scanner.Text(), pos+1, span)))
+	}
+	return &Preview{Code: strings.Join(lines, "\n")}, scanner.Err()
+}
+
+// highlight escapes the text of the line with the given number and marks the
+// part of it covered by the span, if any
+func highlight(text string, line int, span *Location) string {
+	if span == nil || line < span.Line || line > span.EndLine {
+		return html.EscapeString(text)
+	}
+
+	// columns count bytes from 1, the end column is just past the span
+	from, to := 0, len(text)
+	if line == span.Line {
+		from = clamp(span.Column-1, 0, len(text))
+	}
+	if line == span.EndLine {
+		to = clamp(span.EndColumn-1, from, len(text))
+	}
+	return html.EscapeString(text[:from]) +
+		"<mark>" + html.EscapeString(text[from:to]) + "</mark>" +
+		html.EscapeString(text[to:])
+}
+
+func clamp(n, min, max int) int {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
 	}
-	return &Preview{strings.Join(lines, "\n")}, scanner.Err()
+	return n
 }
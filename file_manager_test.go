@@ -0,0 +1,55 @@
+package main
+
+import (
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeProject writes the files, given by their path relative to the project
+// root, into a temporary directory and returns its path
+func writeProject(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "go-search")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range files {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestModulePath(t *testing.T) {
+	assert := assert.New(t)
+
+	root := writeProject(t, map[string]string{"go.mod": "module example.com/proj\n"})
+	defer os.RemoveAll(root)
+	assert.Equal("example.com/proj", modulePath(root))
+
+	gopath, err := ioutil.TempDir("", "gopath")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+	defer func(old string) { build.Default.GOPATH = old }(build.Default.GOPATH)
+	build.Default.GOPATH = gopath
+
+	dir := filepath.Join(gopath, "src", "github.com", "user", "proj")
+	assert.NoError(os.MkdirAll(dir, 0755))
+	assert.Equal("github.com/user/proj", modulePath(dir))
+
+	// outside of the GOPATH there is only the name of the directory
+	plain := writeProject(t, map[string]string{"main.go": "package main\n"})
+	defer os.RemoveAll(plain)
+	assert.Equal(filepath.Base(plain), modulePath(plain))
+}
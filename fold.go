@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// FoldedTrie is a Trie of words folded to lower case, which maps them back to
+// their original spellings for case insensitive search. Words differing only
+// in case, like Index and index, share a single folded entry.
+type FoldedTrie struct {
+	trie      *Trie
+	spellings map[string][]string // folded word to its original spellings
+}
+
+// NewFoldedTrie creates an empty FoldedTrie
+func NewFoldedTrie() *FoldedTrie {
+	return &FoldedTrie{
+		trie:      NewTrie(),
+		spellings: make(map[string][]string),
+	}
+}
+
+// FoldedTrieFromIndex builds a FoldedTrie of the words of the given index
+func FoldedTrieFromIndex(idx *Index) *FoldedTrie {
+	f := NewFoldedTrie()
+	for word := range idx.references {
+		f.Insert(word)
+	}
+	return f
+}
+
+// Insert adds the word to the FoldedTrie, if it isn't there yet
+func (f *FoldedTrie) Insert(word string) {
+	folded := strings.ToLower(word)
+	if hasString(f.spellings[folded], word) {
+		return
+	}
+	f.trie.Insert(folded)
+	// the spellings may be shared with a clone, so they are copied
+	spellings := append(append([]string(nil), f.spellings[folded]...), word)
+	sort.Strings(spellings)
+	f.spellings[folded] = spellings
+}
+
+// Delete removes the word from the FoldedTrie. Other spellings of the word are
+// kept.
+func (f *FoldedTrie) Delete(word string) {
+	folded := strings.ToLower(word)
+	spellings := f.spellings[folded]
+	for i, s := range spellings {
+		if s != word {
+			continue
+		}
+		f.trie.Delete(folded)
+		if len(spellings) == 1 {
+			delete(f.spellings, folded)
+		} else {
+			f.spellings[folded] = append(spellings[:i:i], spellings[i+1:]...)
+		}
+		return
+	}
+}
+
+// Find returns every word starting with the prefix, ignoring case
+func (f *FoldedTrie) Find(prefix string) []string {
+	prefix = strings.ToLower(prefix)
+	n, ok := f.trie.Find(prefix)
+	if !ok {
+		return nil
+	}
+
+	var words []string
+	for _, suffix := range n.Prefixes() {
+		words = append(words, f.spellings[prefix+suffix]...)
+	}
+	return words
+}
+
+// clone returns a copy of the FoldedTrie
+func (f *FoldedTrie) clone() *FoldedTrie {
+	c := &FoldedTrie{
+		trie:      f.trie.clone(),
+		spellings: make(map[string][]string, len(f.spellings)),
+	}
+	for folded, spellings := range f.spellings {
+		c.spellings[folded] = spellings
+	}
+	return c
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFoldedTrie(t *testing.T) {
+	assert := assert.New(t)
+	f := NewFoldedTrie()
+	for _, w := range []string{"BuildIndex", "buildIndex", "Build", "Query"} {
+		f.Insert(w)
+	}
+	f.Insert("Build")
+
+	assert.ElementsMatch([]string{"BuildIndex", "buildIndex", "Build"}, f.Find("build"))
+	assert.Equal([]string{"BuildIndex", "buildIndex"}, f.Find("BUILDINDEX"))
+	assert.Empty(f.Find("index"))
+
+	// other spellings are kept, even in a clone
+	c := f.clone()
+	c.Delete("buildIndex")
+	assert.Equal([]string{"BuildIndex"}, c.Find("buildindex"))
+	assert.Equal([]string{"BuildIndex", "buildIndex"}, f.Find("buildindex"))
+
+	c.Delete("BuildIndex")
+	assert.Equal([]string{"Build"}, c.Find("build"))
+	assert.Empty(c.Find("buildi"))
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path"
+	"regexp"
 	"strconv"
 	"strings"
 )
@@ -54,7 +56,12 @@ func (s *Server) initHandlers() {
 
 	// endpoints for dynamically requesting data
 	s.mux.HandleFunc("/summary.json", s.summaryHandler)
+	s.mux.HandleFunc("/packages.json", s.packagesHandler)
+	s.mux.HandleFunc("/imports.json", s.importsHandler)
 	s.mux.HandleFunc("/preview", s.previewHandler)
+	s.mux.HandleFunc("/definition", s.definitionHandler)
+	s.mux.HandleFunc("/references", s.referencesHandler)
+	s.mux.HandleFunc("/implements", s.implementsHandler)
 	s.mux.HandleFunc("/search", s.searchHandler)
 }
 
@@ -63,7 +70,7 @@ func (s *Server) initHandlers() {
 func (s *Server) summaryHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Println(r.URL.String())
 
-	summary := s.querier.idx.Summary()
+	summary := s.querier.Index().Summary()
 	data, err := json.Marshal(summary)
 	if err != nil {
 		fmt.Printf("Error creating summary: %s\n", err)
@@ -73,6 +80,30 @@ func (s *Server) summaryHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, string(data))
 }
 
+func (s *Server) packagesHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println(r.URL.String())
+
+	data, err := json.Marshal(s.querier.Index().Packages())
+	if err != nil {
+		fmt.Printf("Error listing packages: %s\n", err)
+		fmt.Fprint(w, "{\"error\": \"error listing packages\"}")
+		return
+	}
+	fmt.Fprint(w, string(data))
+}
+
+func (s *Server) importsHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println(r.URL.String())
+
+	data, err := json.Marshal(s.querier.Index().ImportGraph())
+	if err != nil {
+		fmt.Printf("Error creating import graph: %s\n", err)
+		fmt.Fprint(w, "{\"error\": \"error generating import graph\"}")
+		return
+	}
+	fmt.Fprint(w, string(data))
+}
+
 func (s *Server) previewHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Println(r.URL.String())
 
@@ -105,12 +136,37 @@ func (s *Server) previewHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	preview, err := s.fileMgr.GetFilePreview(file[0], lineNum)
+	// optionally highlight a span, given by the column it starts at and the
+	// line and column it ends at
+	var span *Location
+	if col := params.Get("col"); col != "" {
+		span = &Location{Line: lineNum, EndLine: lineNum}
+		if span.Column, err = strconv.Atoi(col); err != nil {
+			fmt.Fprint(w, "{\"error\": \"col must be an integer\"}")
+			return
+		}
+		span.EndColumn = span.Column
+		if endLine := params.Get("end_line"); endLine != "" {
+			if span.EndLine, err = strconv.Atoi(endLine); err != nil {
+				fmt.Fprint(w, "{\"error\": \"end_line must be an integer\"}")
+				return
+			}
+		}
+		if endCol := params.Get("end_col"); endCol != "" {
+			if span.EndColumn, err = strconv.Atoi(endCol); err != nil {
+				fmt.Fprint(w, "{\"error\": \"end_col must be an integer\"}")
+				return
+			}
+		}
+	}
+
+	preview, err := s.fileMgr.GetFilePreview(file[0], lineNum, span)
 	if err != nil {
 		fmt.Printf("Error generating preview for file %s line %d: %s\n", file, lineNum, err)
 		fmt.Fprint(w, "{\"error\": \"error generating preview\"}")
 		return
 	}
+	preview.Constants = s.querier.Index().Constants(file[0], lineNum-PreviewLineOffset+1, lineNum+PreviewLineOffset)
 
 	data, err := json.Marshal(preview)
 	if err != nil {
@@ -121,6 +177,122 @@ func (s *Server) previewHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, string(data))
 }
 
+func (s *Server) definitionHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println(r.URL.String())
+
+	params := r.URL.Query()
+	file := params.Get("file")
+	line := params.Get("line")
+	col := params.Get("col")
+	if file == "" || line == "" || col == "" {
+		fmt.Fprint(w, "{\"error\": \"must specify file, line and col\"}")
+		return
+	}
+
+	lineNum, err := strconv.Atoi(line)
+	if err != nil {
+		fmt.Fprint(w, "{\"error\": \"line must be an integer\"}")
+		return
+	}
+	colNum, err := strconv.Atoi(col)
+	if err != nil {
+		fmt.Fprint(w, "{\"error\": \"col must be an integer\"}")
+		return
+	}
+
+	def, ok := s.querier.Index().Definition(file, lineNum, colNum)
+	if !ok {
+		fmt.Fprint(w, "{\"error\": \"no definition found\"}")
+		return
+	}
+
+	data, err := json.Marshal(def.GetLocation())
+	if err != nil {
+		fmt.Printf("Error marshalling definition response: %s\n", err)
+		fmt.Fprint(w, "{\"error\": \"error finding definition\"}")
+		return
+	}
+	fmt.Fprint(w, string(data))
+}
+
+func (s *Server) referencesHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println(r.URL.String())
+
+	// the symbol is either given by its ID or by the position of a reference
+	// resolve and look up the symbol in the same snapshot
+	idx := s.querier.Index()
+	params := r.URL.Query()
+	id := params.Get("id")
+	if id == "" {
+		file := params.Get("file")
+		line := params.Get("line")
+		if file == "" || line == "" {
+			fmt.Fprint(w, "{\"error\": \"must specify id or file and line\"}")
+			return
+		}
+		lineNum, err := strconv.Atoi(line)
+		if err != nil {
+			fmt.Fprint(w, "{\"error\": \"line must be an integer\"}")
+			return
+		}
+		var colNum int
+		if col := params.Get("col"); col != "" {
+			if colNum, err = strconv.Atoi(col); err != nil {
+				fmt.Fprint(w, "{\"error\": \"col must be an integer\"}")
+				return
+			}
+		}
+
+		var ok bool
+		if id, ok = idx.SymbolAt(file, lineNum, colNum); !ok {
+			fmt.Fprint(w, "{\"error\": \"no symbol found\"}")
+			return
+		}
+	}
+
+	refs, ok := idx.ReferencesBySymbol(id)
+	if !ok {
+		fmt.Fprint(w, "{\"error\": \"unknown symbol\"}")
+		return
+	}
+
+	data, err := json.Marshal(&SymbolReferences{
+		Symbol:     id,
+		References: References(refs).Format(),
+	})
+	if err != nil {
+		fmt.Printf("Error marshalling references response: %s\n", err)
+		fmt.Fprint(w, "{\"error\": \"error finding references\"}")
+		return
+	}
+	fmt.Fprint(w, string(data))
+}
+
+func (s *Server) implementsHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println(r.URL.String())
+
+	// either list the types implementing an interface, or the interfaces
+	// implemented by a type
+	params := r.URL.Query()
+	var refs References
+	if iface := params.Get("interface"); iface != "" {
+		refs = s.querier.Index().Implementations(iface)
+	} else if typ := params.Get("type"); typ != "" {
+		refs = s.querier.Index().Satisfies(typ)
+	} else {
+		fmt.Fprint(w, "{\"error\": \"must specify interface or type\"}")
+		return
+	}
+
+	data, err := json.Marshal(refs.Format())
+	if err != nil {
+		fmt.Printf("Error marshalling implements response: %s\n", err)
+		fmt.Fprint(w, "{\"error\": \"error finding implementations\"}")
+		return
+	}
+	fmt.Fprint(w, string(data))
+}
+
 func (s *Server) searchHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Println(r.URL.String())
 
@@ -137,19 +309,69 @@ func (s *Server) searchHandler(w http.ResponseWriter, r *http.Request) {
 
 	// parse possible query filters
 	opts := DefaultQueryOptions()
-	if file, ok := params["file"]; ok {
-		opts.file = strings.ToLower(file[0])
+	if mode, ok := params["mode"]; ok {
+		switch m := strings.ToLower(mode[0]); m {
+		case ModePrefix, ModeFold, ModeSubstring, ModeFuzzy, ModeAbbrev, ModeRegex, ModeDocs, ModeStrings:
+			opts.mode = m
+		default:
+			fmt.Fprint(w, "{\"error\": \"unknown search mode\"}")
+			return
+		}
+	}
+	if target, ok := params["target"]; ok {
+		switch t := strings.ToLower(target[0]); t {
+		case TargetSymbols, TargetSource:
+			opts.target = t
+		default:
+			fmt.Fprint(w, "{\"error\": \"unknown search target\"}")
+			return
+		}
+	}
+	// file paths are case sensitive, only the filter for all files isn't. The
+	// file may be a directory or a glob pattern too.
+	if file, ok := params["file"]; ok && strings.ToLower(file[0]) != ResultsAll {
+		if _, err := path.Match(file[0], ""); err != nil {
+			fmt.Fprint(w, "{\"error\": \"bad file pattern\"}")
+			return
+		}
+		opts.file = file[0]
 	}
 	if wtype, ok := params["type"]; ok {
 		opts.wtype = strings.ToLower(wtype[0])
 	}
+	if pkg, ok := params["package"]; ok && strings.ToLower(pkg[0]) != ResultsAll {
+		opts.pkg = pkg[0]
+	}
+	if within, ok := params["within"]; ok {
+		opts.within = within[0]
+	}
+	if receiver, ok := params["receiver"]; ok {
+		opts.receiver = receiver[0]
+	}
 	if limit, ok := params["limit"]; ok {
 		if l, err := strconv.Atoi(limit[0]); err == nil {
 			opts.limit = l
 		}
 	}
 
-	refs := s.querier.Query(strings.Join(query, ""), opts)
+	// the filters in the query apply in every mode, while what is left of it
+	// is read as the mode needs, see ParseQuery
+	input, err := ParseQuery(strings.Join(query, ""), opts)
+	if err != nil {
+		data, _ := json.Marshal(map[string]string{"error": err.Error()})
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, string(data))
+		return
+	}
+	if opts.mode == ModeRegex {
+		if _, err := regexp.Compile(input); err != nil {
+			data, _ := json.Marshal(map[string]string{"error": err.Error()})
+			fmt.Fprint(w, string(data))
+			return
+		}
+	}
+
+	refs := s.querier.Query(input, opts)
 	data, err := json.Marshal(refs.Format())
 	if err != nil {
 		fmt.Printf("Error running search: %s\n", err)
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const handlersSource = `package main
+
+import "fmt"
+
+// Export writes the report to the file: report.txt, unless -dry is given
+func Export(dry bool) {
+	flag := "-dry"
+	fmt.Printf("file: %s - \"missing\"\n", flag)
+}
+
+func main() {
+	Export(false)
+}
+`
+
+// search runs the query through the search handler and returns the words of
+// the results
+func search(t *testing.T, s *Server, params url.Values) []string {
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, httptest.NewRequest("GET", "/search?"+params.Encode(), nil))
+
+	var results []*Result
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatalf("bad response %s: %v", w.Body.String(), err)
+	}
+	words := []string{}
+	for _, res := range results {
+		words = append(words, res.Word)
+	}
+	return words
+}
+
+func TestSearchHandlerQueryFilters(t *testing.T) {
+	assert := assert.New(t)
+	root := writeProject(t, map[string]string{"main.go": handlersSource})
+	defer os.RemoveAll(root)
+	fm := NewFileManager(root)
+	idx := BuildIndex(fm)
+	s := NewServer(NewQuerier(idx, TrieFromIndex(idx)), fm)
+
+	// the Printf the message came from, and the flag it contains
+	assert.Equal([]string{`file: %s - "missing"` + "\n", "-dry"}, search(t, s, url.Values{
+		"mode":  {ModeStrings},
+		"query": {`file: -dry - "missing"`},
+	}))
+	assert.Equal([]string{`file: %s - "missing"` + "\n", "-dry"}, search(t, s, url.Values{
+		"mode":  {ModeStrings},
+		"query": {`file: -dry - "missing" in:Export file:main.go`},
+	}))
+	assert.Empty(search(t, s, url.Values{
+		"mode":  {ModeStrings},
+		"query": {`file: -dry in:main`},
+	}))
+	assert.Equal([]string{`flag := "-dry"`}, search(t, s, url.Values{
+		"mode":   {ModeRegex},
+		"target": {TargetSource},
+		"query":  {`"-dry" file:main.go`},
+	}))
+	assert.Empty(search(t, s, url.Values{
+		"mode":   {ModeRegex},
+		"target": {TargetSource},
+		"query":  {`"-dry" -file:main.go`},
+	}))
+
+	// doc comments have to contain the phrases as they are
+	assert.Equal([]string{"Export"}, search(t, s, url.Values{
+		"mode":  {ModeDocs},
+		"query": {`"the file: report.txt" kind:func`},
+	}))
+	assert.Empty(search(t, s, url.Values{
+		"mode":  {ModeDocs},
+		"query": {`"report the file"`},
+	}))
+	assert.Empty(search(t, s, url.Values{
+		"mode":  {ModeDocs},
+		"query": {"report -dry"},
+	}))
+	assert.Empty(search(t, s, url.Values{
+		"mode":  {ModeDocs},
+		"query": {"report kind:struct"},
+	}))
+
+	assert.Equal([]string{"Export"}, search(t, s, url.Values{
+		"mode":  {ModePrefix},
+		"query": {"Ex decl:yes file:main.go"},
+	}))
+	// while no identifier has spaces in it
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, httptest.NewRequest("GET", "/search?"+url.Values{
+		"query": {`"Export report" file:main.go`},
+	}.Encode(), nil))
+	assert.Equal(http.StatusBadRequest, w.Code)
+	assert.Contains(w.Body.String(), "error")
+}
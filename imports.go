@@ -0,0 +1,159 @@
+package main
+
+import (
+	"sort"
+)
+
+// ImportGraph is the response type for the import graph between the packages
+// of the project. Edges refer to nodes by their index, the way d3 expects
+// links for a force layout. Imports from outside the project are left out.
+type ImportGraph struct {
+	Nodes  []*ImportNode `json:"nodes"`
+	Edges  []*ImportEdge `json:"edges"`
+	Cycles [][]string    `json:"cycles"`
+}
+
+// ImportNode is a package in the ImportGraph
+type ImportNode struct {
+	Name       string `json:"name"`
+	ImportPath string `json:"import_path"`
+	FileCount  int    `json:"file_count"`
+	InCycle    bool   `json:"in_cycle"`
+}
+
+// ImportEdge is an import from the Source package of the Target package
+type ImportEdge struct {
+	Source  int  `json:"source"`
+	Target  int  `json:"target"`
+	InCycle bool `json:"in_cycle"`
+}
+
+// ImportGraph builds the import graph of the project packages and detects the
+// import cycles between them. Each cycle is listed as the import paths of the
+// packages taking part in it.
+func (x *Index) ImportGraph() *ImportGraph {
+	pkgs := x.Packages()
+	g := &ImportGraph{
+		Nodes:  make([]*ImportNode, 0, len(pkgs)),
+		Edges:  []*ImportEdge{},
+		Cycles: [][]string{},
+	}
+
+	index := make(map[*Package]int, len(pkgs))
+	for i, pkg := range pkgs {
+		index[pkg] = i
+		g.Nodes = append(g.Nodes, &ImportNode{
+			Name:       pkg.Name,
+			ImportPath: pkg.ImportPath,
+			FileCount:  len(pkg.Files),
+		})
+	}
+
+	adj := make([][]int, len(pkgs))
+	for i, pkg := range pkgs {
+		for _, path := range pkg.Imports {
+			if imported, ok := x.localPackage(path); ok {
+				adj[i] = append(adj[i], index[imported])
+			}
+		}
+	}
+
+	// every strongly connected component with more than one package, or a
+	// package importing itself, is a cycle
+	component := make([]int, len(pkgs))
+	for i, scc := range stronglyConnected(adj) {
+		for _, n := range scc {
+			component[n] = i
+		}
+		if len(scc) == 1 && !hasInt(adj[scc[0]], scc[0]) {
+			continue
+		}
+		var cycle []string
+		for _, n := range scc {
+			g.Nodes[n].InCycle = true
+			cycle = append(cycle, g.Nodes[n].ImportPath)
+		}
+		sort.Strings(cycle)
+		g.Cycles = append(g.Cycles, cycle)
+	}
+
+	for src, targets := range adj {
+		for _, tgt := range targets {
+			g.Edges = append(g.Edges, &ImportEdge{
+				Source:  src,
+				Target:  tgt,
+				InCycle: g.Nodes[src].InCycle && component[src] == component[tgt],
+			})
+		}
+	}
+
+	return g
+}
+
+// stronglyConnected returns the strongly connected components of a graph given
+// as adjacency lists, using Tarjan's algorithm
+func stronglyConnected(adj [][]int) [][]int {
+	var (
+		sccs    [][]int
+		stack   []int
+		counter int
+		index   = make([]int, len(adj))
+		lowlink = make([]int, len(adj))
+		onStack = make([]bool, len(adj))
+		visit   func(v int)
+	)
+	for i := range index {
+		index[i] = -1
+	}
+
+	visit = func(v int) {
+		index[v] = counter
+		lowlink[v] = counter
+		counter++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range adj[v] {
+			if index[w] < 0 {
+				visit(w)
+				if lowlink[w] < lowlink[v] {
+					lowlink[v] = lowlink[w]
+				}
+			} else if onStack[w] && index[w] < lowlink[v] {
+				lowlink[v] = index[w]
+			}
+		}
+
+		if lowlink[v] != index[v] {
+			return
+		}
+		// v is the root of a component, pop it off the stack
+		var scc []int
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			scc = append(scc, w)
+			if w == v {
+				break
+			}
+		}
+		sccs = append(sccs, scc)
+	}
+
+	for v := range adj {
+		if index[v] < 0 {
+			visit(v)
+		}
+	}
+	return sccs
+}
+
+func hasInt(list []int, n int) bool {
+	for _, l := range list {
+		if l == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStronglyConnected(t *testing.T) {
+	// 0 -> 1 -> 2 -> 0, 2 -> 3, 4 -> 4
+	adj := [][]int{{1}, {2}, {0, 3}, {}, {4}}
+	var sccs [][]int
+	for _, scc := range stronglyConnected(adj) {
+		sort.Ints(scc)
+		sccs = append(sccs, scc)
+	}
+	sort.Slice(sccs, func(i, j int) bool { return sccs[i][0] < sccs[j][0] })
+	assert.Equal(t, [][]int{{0, 1, 2}, {3}, {4}}, sccs)
+}
+
+func TestImportGraphCycles(t *testing.T) {
+	assert := assert.New(t)
+	root := writeProject(t, map[string]string{
+		"go.mod":  "module example.com/proj\n",
+		"main.go": "package main\n\nimport _ \"example.com/proj/a\"\n\nfunc main() {}\n",
+		"a/a.go":  "package a\n\nimport _ \"example.com/proj/b\"\n",
+		"b/b.go":  "package b\n\nimport _ \"example.com/proj/a\"\n",
+		// shares the last element of its import path with the project package
+		"c/c.go": "package c\n\nimport _ \"other.com/a\"\n",
+	})
+	defer os.RemoveAll(root)
+
+	g := BuildIndex(NewFileManager(root)).ImportGraph()
+	assert.Equal([][]string{{"example.com/proj/a", "example.com/proj/b"}}, g.Cycles)
+
+	nodes := make(map[string]*ImportNode)
+	for _, n := range g.Nodes {
+		nodes[n.ImportPath] = n
+	}
+	assert.True(nodes["example.com/proj/a"].InCycle)
+	assert.True(nodes["example.com/proj/b"].InCycle)
+	assert.False(nodes["example.com/proj"].InCycle)
+	assert.False(nodes["example.com/proj/c"].InCycle)
+
+	var edges []string
+	for _, e := range g.Edges {
+		edges = append(edges, g.Nodes[e.Source].ImportPath+" -> "+g.Nodes[e.Target].ImportPath)
+	}
+	sort.Strings(edges)
+	assert.Equal([]string{
+		"example.com/proj -> example.com/proj/a",
+		"example.com/proj/a -> example.com/proj/b",
+		"example.com/proj/b -> example.com/proj/a",
+	}, edges)
+}
@@ -5,6 +5,12 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"go/types"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
 )
 
 // Index stores file information and lookup tables that map words to their types
@@ -12,35 +18,314 @@ import (
 type Index struct {
 	fset    *token.FileSet
 	fileMgr *FileManager
+	typed   bool // built with BuildTypedIndex
+	// type information for the package currently being walked, only set when
+	// the Index is built with BuildTypedIndex
+	info *types.Info
 	// mapping of word to different types of interest
 	references map[string][]Reference
 	functions  map[string][]*Function
 	structs    map[string][]*Struct
+	interfaces map[string][]*Interface
+	types      map[string][]*NamedType
+	// packages of the project by import path, and the package of each file
+	packages     map[string]*Package
+	filePackages map[string]*Package
+	fileImports  map[string][]string
+	// mapping of doc comment word to the declarations documented with it
+	docs map[string][]Reference
+	// identifier positions per file, for resolving the word under a cursor
+	idents map[string][]*identSpan
+	// string literals per file
+	literals map[string][]*StringLiteral
+	// mapping of symbol ID to every reference of exactly that symbol, and of
+	// word to the IDs of its symbols
+	symbols     map[string][]Reference
+	wordSymbols map[string][]string
+	// symbol IDs of the references resolved with type information. They are
+	// found while their files are indexed, since the positions of the objects
+	// are relative to the FileSet of that update, and kept for the references
+	// restored from the cache, which have no objects.
+	objectSymbols map[Reference]string
+	// identifiers that may use a struct field, resolved once all the structs
+	// are known
+	fieldUses []*fieldUse
+	// the statement launching each call made by go or defer, and the calls of
+	// function literals waiting for the literal to be named
+	launches     map[*ast.CallExpr]string
+	closureCalls map[*ast.FuncLit]*ast.CallExpr
+	// identifiers of the file being walked that already have a Reference
+	indexed map[*ast.Ident]bool
+}
+
+// fieldUse is an identifier that may refer to a struct field
+type fieldUse struct {
+	ident *ast.Ident
+	owner string // struct the field is selected from, if known
+	obj   types.Object
+}
+
+// identSpan marks where the identifier of a Reference appears on a line
+type identSpan struct {
+	Line   int
+	Column int
+	End    int // column just past the identifier
+	ref    Reference
 }
 
 // BuildIndex constructs the Index by walking the files and parsing their ASTs
 func BuildIndex(fm *FileManager) *Index {
-	fset := token.NewFileSet()
-	idx := &Index{
-		fset:       fset,
+	idx := newIndex(fm)
+	idx.indexFiles(fm.files)
+	idx.finish()
+	return idx
+}
+
+// indexFiles parses the given files and walks their ASTs
+func (x *Index) indexFiles(files []string) {
+	for _, f := range parseFiles(x.fset, files) {
+		if f != nil {
+			ast.Walk(x, f)
+		}
+	}
+}
+
+// parseFiles parses the files on a pool of workers, one per CPU. The ASTs are
+// returned in the order of the files, with nil for the files that could not be
+// parsed, so walking them stays deterministic.
+func parseFiles(fset *token.FileSet, files []string) []*ast.File {
+	asts := make([]*ast.File, len(files))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				f, err := parser.ParseFile(fset, files[i], nil, parser.AllErrors|parser.ParseComments)
+				if err != nil {
+					fmt.Printf("could not parse %s: %v\n", files[i], err)
+					continue
+				}
+				asts[i] = f
+			}
+		}()
+	}
+
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return asts
+}
+
+// finish runs the passes that need every file to be indexed first
+func (x *Index) finish() {
+	files := make([]string, 0, len(x.idents))
+	for file := range x.idents {
+		files = append(files, file)
+	}
+	for file := range x.literals {
+		if _, ok := x.idents[file]; !ok {
+			files = append(files, file)
+		}
+	}
+	words := make([]string, 0, len(x.references))
+	for word := range x.references {
+		words = append(words, word)
+	}
+	x.finishFiles(files, words)
+}
+
+// finishFiles runs the passes that need every file to be indexed first, over
+// the references of the given files and the symbols of the words that gained
+// or lost references. The references of the other files are left as they are.
+func (x *Index) finishFiles(files, words []string) {
+	x.indexed = nil
+	x.linkPackages()
+	x.resolveFields()
+	x.scopeReferences(files)
+	x.completeInterfaces(files)
+	x.recordSymbols(files)
+	x.groupSymbols(words)
+}
+
+// Update re-indexes the files that were added or changed and drops the
+// references of the files that were removed. With type information the other
+// files of the changed packages are re-indexed as well, since their objects are
+// checked together. Only the references of those files are scoped and resolved
+// again, along with the symbols of their words. Returns every word that lost or
+// gained references.
+func (x *Index) Update(changed, removed []string) []string {
+	if x.typed {
+		changed = x.packageFiles(changed)
+	}
+	// positions are only looked up while the files are indexed, so every
+	// update gets a FileSet of its own rather than growing a shared one
+	x.fset = token.NewFileSet()
+
+	var words []string
+	for _, file := range changed {
+		words = append(words, x.removeFile(x.fileMgr.Rel(file))...)
+	}
+	for _, file := range removed {
+		words = append(words, x.removeFile(x.fileMgr.Rel(file))...)
+	}
+
+	if x.typed {
+		x.indexTypedFiles(changed)
+	} else {
+		x.indexFiles(changed)
+	}
+
+	files := make([]string, 0, len(changed))
+	for _, file := range changed {
+		relPath := x.fileMgr.Rel(file)
+		files = append(files, relPath)
+		for _, span := range x.idents[relPath] {
+			words = append(words, referenceName(span.ref))
+		}
+	}
+	x.finishFiles(files, words)
+	return words
+}
+
+// packageFiles returns the project files in the same directories as the given
+// files
+func (x *Index) packageFiles(files []string) []string {
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		dirs[filepath.Dir(f)] = true
+	}
+	var pkgFiles []string
+	for _, f := range x.fileMgr.files {
+		if dirs[filepath.Dir(f)] {
+			pkgFiles = append(pkgFiles, f)
+		}
+	}
+	return pkgFiles
+}
+
+// removeFile drops every reference found in the file, along with the file
+// itself from its package. Returns the words of the dropped references.
+func (x *Index) removeFile(relPath string) []string {
+	stale := make(map[Reference]bool)
+	for _, span := range x.idents[relPath] {
+		stale[span.ref] = true
+		delete(x.objectSymbols, span.ref)
+		x.removeDoc(span.ref)
+	}
+	delete(x.idents, relPath)
+	delete(x.literals, relPath)
+
+	for ref := range stale {
+		name := referenceName(ref)
+		if refs := withoutReferences(x.references[name], stale); len(refs) > 0 {
+			x.references[name] = refs
+		} else {
+			delete(x.references, name)
+		}
+
+		// the lists may be shared with a clone, so they are built anew
+		switch r := ref.(type) {
+		case *Function:
+			var fns []*Function
+			for _, fn := range x.functions[name] {
+				if fn != r {
+					fns = append(fns, fn)
+				}
+			}
+			if len(fns) > 0 {
+				x.functions[name] = fns
+			} else {
+				delete(x.functions, name)
+			}
+		case *Struct:
+			var structs []*Struct
+			for _, st := range x.structs[name] {
+				if st != r {
+					structs = append(structs, st)
+				}
+			}
+			if len(structs) > 0 {
+				x.structs[name] = structs
+			} else {
+				delete(x.structs, name)
+			}
+		case *Interface:
+			var ifaces []*Interface
+			for _, iface := range x.interfaces[name] {
+				if iface != r {
+					ifaces = append(ifaces, iface)
+				}
+			}
+			if len(ifaces) > 0 {
+				x.interfaces[name] = ifaces
+			} else {
+				delete(x.interfaces, name)
+			}
+		case *NamedType:
+			var named []*NamedType
+			for _, t := range x.types[name] {
+				if t != r {
+					named = append(named, t)
+				}
+			}
+			if len(named) > 0 {
+				x.types[name] = named
+			} else {
+				delete(x.types, name)
+			}
+		}
+	}
+
+	x.removePackageFile(relPath)
+
+	words := make([]string, 0, len(stale))
+	for ref := range stale {
+		words = append(words, referenceName(ref))
+	}
+	return words
+}
+
+// withoutReferences returns the references that aren't stale. refs may be
+// shared with a clone, so it is left as is.
+func withoutReferences(refs []Reference, stale map[Reference]bool) []Reference {
+	var kept []Reference
+	for _, ref := range refs {
+		if !stale[ref] {
+			kept = append(kept, ref)
+		}
+	}
+	return kept
+}
+
+func newIndex(fm *FileManager) *Index {
+	return &Index{
+		fset:       token.NewFileSet(),
 		fileMgr:    fm,
 		references: make(map[string][]Reference),
 		functions:  make(map[string][]*Function),
 		structs:    make(map[string][]*Struct),
-	}
+		interfaces: make(map[string][]*Interface),
+		types:      make(map[string][]*NamedType),
+		idents:     make(map[string][]*identSpan),
+		docs:       make(map[string][]Reference),
+		literals:   make(map[string][]*StringLiteral),
 
-	for _, arg := range idx.fileMgr.files {
-		f, err := parser.ParseFile(fset, arg, nil, parser.AllErrors)
-		if err != nil {
-			fmt.Printf("could not parse %s: %v\n", arg, err)
-			continue
-		}
-		ast.Walk(idx, f)
-	}
+		launches:     make(map[*ast.CallExpr]string),
+		closureCalls: make(map[*ast.FuncLit]*ast.CallExpr),
 
-	idx.scopeReferences()
+		packages:     make(map[string]*Package),
+		filePackages: make(map[string]*Package),
+		fileImports:  make(map[string][]string),
 
-	return idx
+		symbols:       make(map[string][]Reference),
+		wordSymbols:   make(map[string][]string),
+		objectSymbols: make(map[Reference]string),
+	}
 }
 
 // ReferencesByWord returns all references to the given word. Returns true if the word
@@ -50,99 +335,409 @@ func (x *Index) ReferencesByWord(word string) ([]Reference, bool) {
 	return refs, ok
 }
 
+// Constants returns the constants declared from the start to the end line of
+// a file, inclusive
+func (x *Index) Constants(file string, start, end int) []*Constant {
+	var consts []*Constant
+	for _, span := range x.idents[file] {
+		if c, ok := span.ref.(*Constant); ok && c.IsDecl && c.Line >= start && c.Line <= end {
+			consts = append(consts, c)
+		}
+	}
+	return consts
+}
+
 // Functions returns a list of all Function declarations
 func (x *Index) Functions() Functions {
 	return x.functions
 }
 
-func (x *Index) addReference(word string, ref Reference) {
+func (x *Index) addReference(ident *ast.Ident, ref Reference) {
+	if x.indexed != nil {
+		x.indexed[ident] = true
+	}
+	x.addReferenceAt(ident.Name, ident.Pos(), len(ident.Name), ref)
+}
+
+// addReferenceAt adds a reference to the word, spanning size columns from the
+// given position. This is for references without an identifier of their own,
+// like function literals.
+func (x *Index) addReferenceAt(word string, p token.Pos, size int, ref Reference) {
 	x.references[word] = append(x.references[word], ref)
+
+	pos := x.fset.Position(p)
+	relPath := x.fileMgr.Rel(pos.Filename)
+	x.idents[relPath] = append(x.idents[relPath], &identSpan{
+		Line:   pos.Line,
+		Column: pos.Column,
+		End:    pos.Column + size,
+		ref:    ref,
+	})
+}
+
+// objectOf returns the object the identifier resolves to. It is always nil
+// unless the Index is built with type information.
+func (x *Index) objectOf(ident *ast.Ident) types.Object {
+	if x.info == nil {
+		return nil
+	}
+	return x.info.ObjectOf(ident)
 }
 
-func (x *Index) addFunction(name string, body *ast.BlockStmt, recv string) {
-	if x.fset == nil || body == nil {
+// addFunction indexes a function declaration, from its func keyword to the end
+// of its body
+func (x *Index) addFunction(decl *ast.FuncDecl, recv string) {
+	if x.fset == nil || decl.Body == nil {
 		return
 	}
-	posStart := x.fset.Position(body.Lbrace)
-	posEnd := x.fset.Position(body.Rbrace)
-	relPath := x.fileMgr.Rel(posStart.Filename)
+	loc := x.location(decl.Pos(), decl.End())
 	f := &Function{
-		Location: &Location{
-			File: relPath,
-			Line: posStart.Line,
-		},
-		Name:     name,
+		Location: loc,
+		Name:     decl.Name.Name,
 		IsDecl:   true,
-		Size:     posEnd.Line - posStart.Line + 1,
+		Size:     loc.EndLine - loc.Line + 1,
 		Reciever: recv,
+		Doc:      docText(decl.Doc),
+		Object:   x.objectOf(decl.Name),
 	}
 
-	x.functions[name] = append(x.functions[name], f)
-	x.addReference(name, f)
+	x.functions[f.Name] = append(x.functions[f.Name], f)
+	x.addReference(decl.Name, f)
+	x.addDoc(f)
 }
 
-func (x *Index) addFunctionCall(name string, n ast.Node, recv string) {
-	pos := x.fset.Position(n.Pos())
-	relPath := x.fileMgr.Rel(pos.Filename)
+func (x *Index) addFunctionCall(ident *ast.Ident, call *ast.CallExpr, recv string) {
+	f := &Function{
+		Location: x.location(call.Pos(), call.End()),
+		Name:     ident.Name,
+		Reciever: recv,
+		Launch:   x.launches[call],
+		Object:   x.objectOf(ident),
+	}
+	if fn, ok := f.Object.(*types.Func); ok {
+		if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+			f.recvType = receiverName(recv.Type())
+		}
+	}
+	delete(x.launches, call)
+	x.addReference(ident, f)
+}
+
+// addFuncLit indexes a function literal as a Function of its own with the
+// given name. If the literal is called right away, eg. by a go statement, the
+// call is indexed too.
+func (x *Index) addFuncLit(lit *ast.FuncLit, name, recv string) {
+	loc := x.location(lit.Pos(), lit.End())
 	f := &Function{
-		Location: &Location{
-			File: relPath,
-			Line: pos.Line,
-		},
+		Location: loc,
+		Name:     name,
+		IsDecl:   true,
+		Size:     loc.EndLine - loc.Line + 1,
+		Reciever: recv,
+		Literal:  true,
+	}
+	x.functions[name] = append(x.functions[name], f)
+	x.addReferenceAt(name, lit.Type.Func, len("func"), f)
+
+	call, ok := x.closureCalls[lit]
+	if !ok {
+		return
+	}
+	delete(x.closureCalls, lit)
+	c := &Function{
+		Location: x.location(call.Lparen, call.End()),
 		Name:     name,
 		Reciever: recv,
+		Launch:   x.launches[call],
 	}
-	x.addReference(name, f)
+	delete(x.launches, call)
+	x.addReferenceAt(name, call.Lparen, len("()"), c)
 }
 
-func (x *Index) addVariable(name string, n ast.Node, isDecl bool) {
-	pos := x.fset.Position(n.Pos())
-	relPath := x.fileMgr.Rel(pos.Filename)
+func (x *Index) addVariable(ident *ast.Ident, n ast.Node, isDecl bool) {
 	v := &Variable{
-		Location: &Location{
-			File: relPath,
-			Line: pos.Line,
-		},
-		Name:   name,
-		IsDecl: isDecl,
+		Location: x.location(n.Pos(), n.End()),
+		Name:     ident.Name,
+		IsDecl:   isDecl,
+		Object:   x.objectOf(ident),
+	}
+	if obj := v.Object; obj != nil {
+		v.global = obj.Pkg() != nil && obj.Parent() == obj.Pkg().Scope()
 	}
 
-	x.addReference(name, v)
+	x.addReference(ident, v)
 }
 
-func (x *Index) addStruct(name string, n ast.Node) {
-	pos := x.fset.Position(n.Pos())
-	relPath := x.fileMgr.Rel(pos.Filename)
+func (x *Index) addStruct(ident *ast.Ident, st *ast.StructType, n ast.Node, doc *ast.CommentGroup) {
 	s := &Struct{
-		Name: name,
-		Location: &Location{
-			File: relPath,
-			Line: pos.Line,
-		},
+		Name:     ident.Name,
+		Location: x.location(n.Pos(), n.End()),
+		Doc:      docText(doc),
+		Object:   x.objectOf(ident),
+	}
+	s.methods = methodSignatures(s.Object)
+
+	for _, field := range st.Fields.List {
+		x.addStructFields(s, field)
+	}
+
+	x.structs[ident.Name] = append(x.structs[ident.Name], s)
+	x.addReference(ident, s)
+	x.addDoc(s)
+}
+
+// addStructFields records the names declared by a field list entry on the
+// Struct and indexes each of them as a Field declaration
+func (x *Index) addStructFields(s *Struct, field *ast.Field) {
+	var tag string
+	if field.Tag != nil {
+		tag, _ = strconv.Unquote(field.Tag.Value)
+	}
+
+	names := field.Names
+	if len(names) == 0 {
+		// embedded fields are named after their type
+		if name := embeddedName(field.Type); name != nil {
+			names = []*ast.Ident{name}
+		}
+	}
+
+	for _, name := range names {
+		s.Fields = append(s.Fields, &StructField{
+			Name:     name.Name,
+			Type:     types.ExprString(field.Type),
+			Tag:      tag,
+			Embedded: len(field.Names) == 0,
+		})
+
+		f := &Field{
+			Location: x.location(name.Pos(), name.End()),
+			Name:     name.Name,
+			Struct:   s.Name,
+			IsDecl:   true,
+			Object:   x.objectOf(name),
+		}
+		x.addReference(name, f)
+	}
+}
+
+// addFieldUse records an identifier that might select a struct field. Without
+// type information every selector is a candidate, so they are only turned into
+// Field references by resolveFields.
+func (x *Index) addFieldUse(ident *ast.Ident, owner string) {
+	obj := x.objectOf(ident)
+	if x.info != nil {
+		if v, ok := obj.(*types.Var); !ok || !v.IsField() {
+			return
+		}
+	}
+	x.indexed[ident] = true
+	x.fieldUses = append(x.fieldUses, &fieldUse{ident, owner, obj})
+}
+
+// resolveFields turns the recorded field uses into Field references to their
+// owning struct. With type information the owner is the struct that declares
+// the field object, which is looked up by the position of the declaration
+// since it may come from an earlier load of its package. Otherwise a use is
+// only kept if some struct in the project has a field of that name, and the
+// owner is only set if exactly one does.
+func (x *Index) resolveFields() {
+	// the structs declaring fields at each offset of a file, and the structs
+	// with a field of each name, collected as uses need them
+	declared := make(map[string]map[int]string)
+	byName := make(map[string][]string)
+	ownersAt := func(file string) map[int]string {
+		owners, ok := declared[file]
+		if !ok {
+			owners = make(map[int]string)
+			for _, span := range x.idents[file] {
+				if f, ok := span.ref.(*Field); ok && f.IsDecl {
+					owners[f.Offset] = f.Struct
+				}
+			}
+			declared[file] = owners
+		}
+		return owners
+	}
+	structsOf := func(name string) []string {
+		structs, ok := byName[name]
+		if !ok {
+			for _, ref := range x.references[name] {
+				if f, ok := ref.(*Field); ok && f.IsDecl {
+					structs = append(structs, f.Struct)
+				}
+			}
+			byName[name] = structs
+		}
+		return structs
+	}
+
+	for _, use := range x.fieldUses {
+		owner := use.owner
+		if use.obj != nil {
+			pos := x.fset.Position(use.obj.Pos())
+			if o, ok := ownersAt(x.fileMgr.Rel(pos.Filename))[pos.Offset]; ok {
+				owner = o
+			}
+		} else {
+			structs := structsOf(use.ident.Name)
+			if len(structs) == 0 || (owner != "" && !hasString(structs, owner)) {
+				continue
+			}
+			if owner == "" && len(structs) == 1 {
+				owner = structs[0]
+			}
+		}
+
+		f := &Field{
+			Location: x.location(use.ident.Pos(), use.ident.End()),
+			Name:     use.ident.Name,
+			Struct:   owner,
+			Object:   use.obj,
+		}
+		x.addReference(use.ident, f)
+	}
+	x.fieldUses = nil
+}
+
+func (x *Index) addConstant(ident *ast.Ident, n ast.Node, isDecl bool, value string) {
+	c := &Constant{
+		Location: x.location(n.Pos(), n.End()),
+		Name:     ident.Name,
+		Value:    value,
+		IsDecl:   isDecl,
+		Object:   x.objectOf(ident),
+	}
+	if obj, ok := c.Object.(*types.Const); ok {
+		// the type checker has already evaluated the constant expression
+		c.Value = obj.Val().ExactString()
+	}
+
+	x.addReference(ident, c)
+}
+
+func (x *Index) addStringLiteral(lit *ast.BasicLit) {
+	value, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return
+	}
+	l := &StringLiteral{
+		Location: x.location(lit.Pos(), lit.End()),
+		Value:    value,
+	}
+	x.literals[l.File] = append(x.literals[l.File], l)
+}
+
+func (x *Index) addNamedType(ident *ast.Ident, spec *ast.TypeSpec, n ast.Node, doc *ast.CommentGroup) {
+	t := &NamedType{
+		Name:     ident.Name,
+		Location: x.location(n.Pos(), n.End()),
+		Type:     types.ExprString(spec.Type),
+		Doc:      docText(doc),
+		Object:   x.objectOf(ident),
+	}
+	t.Kind = typeKind(spec, t.Object)
+	t.methods = methodSignatures(t.Object)
+
+	x.types[ident.Name] = append(x.types[ident.Name], t)
+	x.addReference(ident, t)
+	x.addDoc(t)
+}
+
+func (x *Index) addInterface(ident *ast.Ident, iface *ast.InterfaceType, n ast.Node, doc *ast.CommentGroup) {
+	i := &Interface{
+		Name:     ident.Name,
+		Location: x.location(n.Pos(), n.End()),
+		Doc:      docText(doc),
+		Object:   x.objectOf(ident),
+	}
+
+	i.methods = methodSignatures(i.Object)
+	if i.Object != nil {
+		// the type checker already knows the complete method set
+		if t, ok := i.Object.Type().Underlying().(*types.Interface); ok {
+			for j := 0; j < t.NumMethods(); j++ {
+				i.Methods = append(i.Methods, t.Method(j).Name())
+			}
+		}
+	} else {
+		// embedded interfaces are expanded by completeInterfaces once the
+		// whole project is indexed
+		for _, field := range iface.Methods.List {
+			for _, name := range field.Names {
+				i.Methods = append(i.Methods, name.Name)
+			}
+			switch t := field.Type.(type) {
+			case *ast.Ident:
+				if len(field.Names) == 0 {
+					i.embeds = append(i.embeds, t.Name)
+				}
+			case *ast.SelectorExpr:
+				i.embeds = append(i.embeds, t.Sel.Name)
+			}
+		}
 	}
 
-	x.structs[name] = append(x.structs[name], s)
-	x.addReference(name, s)
+	x.interfaces[ident.Name] = append(x.interfaces[ident.Name], i)
+	x.addReference(ident, i)
+	x.addDoc(i)
 }
 
-// XXX: this function is terrible but it gets the job done
-// determines the scopes for the different items parsed from the files
-// ex. determine that variable 'idx' is referenced within fn 'main'
-func (x *Index) scopeReferences() {
-	for _, refs := range x.references {
+// scopeReferences determines the scopes for the different items parsed from
+// the given files, ex. determine that variable 'idx' is referenced within fn
+// 'main'. Each reference is scoped to the innermost function wrapping it, and
+// only that function is credited with the calls it makes. The functions and
+// references of each file are swept in order of their offsets, keeping a stack
+// of the functions that are still open.
+func (x *Index) scopeReferences(files []string) {
+	for _, file := range files {
+		var fns []*Function
+		var refs []Reference
+		for _, span := range x.idents[file] {
+			if fn, ok := span.ref.(*Function); ok && fn.IsDecl {
+				fn.Calls = nil
+				fn.calls = nil
+				fns = append(fns, fn)
+			}
+			refs = append(refs, span.ref)
+		}
+		for _, l := range x.literals[file] {
+			refs = append(refs, l)
+		}
+
+		// outer functions sort before the functions nested in them
+		sort.Slice(fns, func(i, j int) bool {
+			if fns[i].Offset != fns[j].Offset {
+				return fns[i].Offset < fns[j].Offset
+			}
+			return fns[i].EndOffset > fns[j].EndOffset
+		})
+
+		sort.SliceStable(refs, func(i, j int) bool {
+			return refs[i].GetLocation().Offset < refs[j].GetLocation().Offset
+		})
+
+		var open []*Function
+		next := 0
 		for _, ref := range refs {
 			loc := ref.GetLocation()
-			for _, fns := range x.functions {
-				for _, fn := range fns {
-					if !fn.Wraps(loc) {
-						// loc not within func block
-						continue
-					}
-					loc.Within = fn.Info()
-					if fnCall, ok := ref.(*Function); ok {
-						fn.Calls = append(fn.Calls, fnCall.Name)
-					}
-				}
+			for next < len(fns) && fns[next].Offset <= loc.Offset {
+				open = append(open, fns[next])
+				next++
+			}
+			for len(open) > 0 && !open[len(open)-1].Wraps(loc) {
+				open = open[:len(open)-1]
+			}
+
+			loc.Within = ""
+			if len(open) == 0 {
+				continue
+			}
+			fn := open[len(open)-1]
+			loc.Within = fn.Info()
+			if call, ok := ref.(*Function); ok && !call.IsDecl {
+				fn.Calls = append(fn.Calls, call.Name)
+				fn.calls = append(fn.calls, call)
 			}
 		}
 	}
@@ -157,6 +752,14 @@ func (x *Index) Visit(n ast.Node) ast.Visitor {
 	}
 
 	switch d := n.(type) {
+	case *ast.File:
+		x.indexed = make(map[*ast.Ident]bool)
+		x.addFile(d)
+		// function literals outside of any function are named like the
+		// runtime does, eg. glob..func1
+		return &closureScope{x: x, name: "glob."}
+	case *ast.Ident:
+		x.addIdent(d)
 	case *ast.IfStmt:
 		x.local(d.Cond)
 	case *ast.AssignStmt:
@@ -172,17 +775,59 @@ func (x *Index) Visit(n ast.Node) ast.Visitor {
 		}
 		switch fun := d.Fun.(type) {
 		case *ast.Ident:
-			x.addFunctionCall(fun.Name, n, "")
+			x.addFunctionCall(fun, d, "")
 		case *ast.SelectorExpr:
 			var obj string
 			if x, ok := fun.X.(*ast.Ident); ok {
 				obj = x.Name
 			}
-			x.addFunctionCall(fun.Sel.Name, n, obj)
+			x.addFunctionCall(fun.Sel, d, obj)
+		case *ast.FuncLit:
+			// indexed once the literal is named
+			x.closureCalls[fun] = d
+		default:
+			delete(x.launches, d)
 		}
+	case *ast.BasicLit:
+		if d.Kind == token.STRING {
+			x.addStringLiteral(d)
+		}
+	case *ast.ImportSpec:
+		// import paths are not worth searching for
+		return nil
+	case *ast.Field:
+		// and neither are struct tags
+		if d.Tag != nil {
+			ast.Walk(x, d.Type)
+			return nil
+		}
+	case *ast.GoStmt:
+		x.launches[d.Call] = "go"
+	case *ast.DeferStmt:
+		x.launches[d.Call] = "defer"
 	case *ast.RangeStmt:
 		x.local(d.Key)
 		x.local(d.Value)
+	case *ast.SelectorExpr:
+		var owner string
+		if x.info != nil {
+			if sel, ok := x.info.Selections[d]; ok {
+				owner = receiverName(sel.Recv())
+			}
+		}
+		x.addFieldUse(d.Sel, owner)
+	case *ast.CompositeLit:
+		var owner string
+		if t, ok := d.Type.(*ast.Ident); ok {
+			owner = t.Name
+		}
+		for _, elt := range d.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				if key, ok := kv.Key.(*ast.Ident); ok {
+					x.addFieldUse(key, owner)
+				}
+			}
+		}
 	case *ast.FuncDecl:
 		if d.Recv != nil {
 			x.localList(d.Recv.List, token.FUNC)
@@ -192,7 +837,8 @@ func (x *Index) Visit(n ast.Node) ast.Visitor {
 			x.localList(d.Type.Results.List, token.FUNC)
 		}
 		recv := parseFuncReceiver(d.Recv)
-		x.addFunction(d.Name.Name, d.Body, recv)
+		x.addFunction(d, recv)
+		return &closureScope{x: x, name: d.Name.Name, recv: recv}
 	case *ast.GenDecl:
 		if d.Tok == token.VAR {
 			for _, spec := range d.Specs {
@@ -201,14 +847,55 @@ func (x *Index) Visit(n ast.Node) ast.Visitor {
 						if name.Name == "_" {
 							continue
 						}
-						x.addVariable(name.Name, n, true)
+						x.addVariable(name, name, true)
+					}
+				}
+			}
+		} else if d.Tok == token.CONST {
+			// a spec without values repeats the previous ones, with iota
+			// counting up from 0 for every spec in the block
+			var values []ast.Expr
+			for specIdx, spec := range d.Specs {
+				value, ok := spec.(*ast.ValueSpec)
+				if !ok {
+					continue
+				}
+				if len(value.Values) > 0 {
+					values = value.Values
+				}
+				for i, name := range value.Names {
+					if name.Name == "_" {
+						continue
 					}
+					x.addConstant(name, name, true, constValue(values, i, specIdx))
 				}
 			}
 		} else if d.Tok == token.TYPE {
 			for _, spec := range d.Specs {
-				if value, ok := spec.(*ast.TypeSpec); ok {
-					x.addStruct(value.Name.String(), n)
+				value, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				// the doc comment of a lone spec belongs to its declaration
+				doc := value.Doc
+				if doc == nil && len(d.Specs) == 1 {
+					doc = d.Doc
+				}
+				switch t := value.Type.(type) {
+				case *ast.InterfaceType:
+					if value.Assign.IsValid() {
+						x.addNamedType(value.Name, value, value, doc)
+					} else {
+						x.addInterface(value.Name, t, value, doc)
+					}
+				case *ast.StructType:
+					if value.Assign.IsValid() {
+						x.addNamedType(value.Name, value, value, doc)
+					} else {
+						x.addStruct(value.Name, t, value, doc)
+					}
+				default:
+					x.addNamedType(value.Name, value, value, doc)
 				}
 			}
 		}
@@ -217,6 +904,39 @@ func (x *Index) Visit(n ast.Node) ast.Visitor {
 	return x
 }
 
+// closureScope walks the nodes within a function, naming the function literals
+// in it after the function the way the runtime does: main.func1, main.func2,
+// and main.func1.1 for a literal within the first one.
+type closureScope struct {
+	x       *Index
+	name    string
+	recv    string
+	literal bool // the function is a literal itself
+	count   int
+}
+
+func (s *closureScope) Visit(n ast.Node) ast.Visitor {
+	lit, ok := n.(*ast.FuncLit)
+	if !ok {
+		if v := s.x.Visit(n); v != s.x {
+			return v
+		}
+		return s
+	}
+
+	s.count++
+	name := fmt.Sprintf("%s.func%d", s.name, s.count)
+	if s.literal {
+		name = fmt.Sprintf("%s.%d", s.name, s.count)
+	}
+	s.x.addFuncLit(lit, name, s.recv)
+	s.x.localList(lit.Type.Params.List, token.FUNC)
+	if lit.Type.Results != nil {
+		s.x.localList(lit.Type.Results.List, token.FUNC)
+	}
+	return &closureScope{x: s.x, name: name, recv: s.recv, literal: true}
+}
+
 func (x *Index) local(n ast.Node) {
 	ident, ok := n.(*ast.Ident)
 	if !ok {
@@ -225,10 +945,65 @@ func (x *Index) local(n ast.Node) {
 	if ident.Name == "_" || ident.Name == "" {
 		return
 	}
+	if x.info != nil {
+		// every identifier is indexed by addIdent instead
+		return
+	}
 	if ident.Obj != nil && ident.Obj.Pos() == ident.Pos() {
-		x.addVariable(ident.Name, n, true)
+		x.addVariable(ident, n, true)
 	} else {
-		x.addVariable(ident.Name, n, false)
+		x.addVariable(ident, n, false)
+	}
+}
+
+// addIdent indexes an identifier by the object it resolves to, unless it was
+// indexed already as part of the node it is in. This catches the uses of
+// variables, constants and functions anywhere in an expression, eg. in a return
+// statement or as an operand. With type information an identifier is a
+// declaration if and only if it defines an object. Without it, only the objects
+// the parser resolves within the file are known.
+func (x *Index) addIdent(ident *ast.Ident) {
+	if x.indexed[ident] || ident.Name == "_" {
+		return
+	}
+	if x.info == nil {
+		if ident.Obj == nil {
+			return
+		}
+		isDecl := ident.Obj.Pos() == ident.Pos()
+		switch ident.Obj.Kind {
+		case ast.Var:
+			// the names a field list declares are indexed with their Struct or
+			// function
+			if _, ok := ident.Obj.Decl.(*ast.Field); !ok || !isDecl {
+				x.addVariable(ident, ident, isDecl)
+			}
+		case ast.Con:
+			x.addConstant(ident, ident, isDecl, "")
+		case ast.Fun:
+			if !isDecl {
+				x.addVariable(ident, ident, false)
+			}
+		}
+		return
+	}
+	_, isDecl := x.info.Defs[ident]
+	switch obj := x.info.ObjectOf(ident).(type) {
+	case *types.Var:
+		if !obj.IsField() {
+			x.addVariable(ident, ident, isDecl)
+		} else if !isDecl {
+			// fields declared by a struct type are indexed with the Struct
+			x.addFieldUse(ident, "")
+		}
+	case *types.Const:
+		x.addConstant(ident, ident, isDecl, "")
+	case *types.Func:
+		// functions are declared by their FuncDecl, and a function used as a
+		// value rather than called is a Variable like any other value
+		if !isDecl {
+			x.addVariable(ident, ident, false)
+		}
 	}
 }
 
@@ -240,6 +1015,89 @@ func (x *Index) localList(fs []*ast.Field, t token.Token) {
 	}
 }
 
+// constValue returns the value of the i-th constant of a spec without type
+// information. That is the source of its expression, except for a bare iota
+// which is simple enough to evaluate, given the index n of the spec.
+func constValue(values []ast.Expr, i, n int) string {
+	if i >= len(values) {
+		return ""
+	}
+	if ident, ok := values[i].(*ast.Ident); ok && ident.Name == "iota" {
+		return strconv.Itoa(n)
+	}
+	return types.ExprString(values[i])
+}
+
+// typeKind returns the NamedType kind of a type declaration. The type
+// expression decides it where it can, otherwise the underlying type is used if
+// there is type information.
+func typeKind(spec *ast.TypeSpec, obj types.Object) string {
+	if spec.Assign.IsValid() {
+		return TypeKindAlias
+	}
+
+	switch t := spec.Type.(type) {
+	case *ast.FuncType:
+		return TypeKindFunc
+	case *ast.MapType:
+		return TypeKindMap
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return TypeKindSlice
+		}
+		return TypeKindArray
+	case *ast.ChanType:
+		return TypeKindChan
+	case *ast.StarExpr:
+		return TypeKindPointer
+	case *ast.Ident:
+		if obj == nil {
+			// without type info only predeclared types are known to be basic
+			if o := types.Universe.Lookup(t.Name); o != nil {
+				if _, ok := o.Type().(*types.Basic); ok {
+					return TypeKindBasic
+				}
+			}
+			return TypeKindNamed
+		}
+	}
+
+	if obj == nil {
+		return TypeKindNamed
+	}
+	switch obj.Type().Underlying().(type) {
+	case *types.Basic:
+		return TypeKindBasic
+	case *types.Signature:
+		return TypeKindFunc
+	case *types.Map:
+		return TypeKindMap
+	case *types.Slice:
+		return TypeKindSlice
+	case *types.Array:
+		return TypeKindArray
+	case *types.Chan:
+		return TypeKindChan
+	case *types.Pointer:
+		return TypeKindPointer
+	}
+	return TypeKindNamed
+}
+
+// embeddedName returns the identifier naming an embedded field, eg. Mutex
+// for *sync.Mutex
+func embeddedName(typ ast.Expr) *ast.Ident {
+	switch t := typ.(type) {
+	case *ast.Ident:
+		return t
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel
+	}
+	return nil
+}
+
 func parseFuncReceiver(recv *ast.FieldList) string {
 	if recv == nil || len(recv.List) == 0 {
 		return ""
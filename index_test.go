@@ -0,0 +1,421 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuerierUpdateTyped(t *testing.T) {
+	assert := assert.New(t)
+	main := "package main\n\nimport \"example.com/proj/util\"\n\ntype counter interface {\n\tCount() util.Total\n}\n\nfunc main() {\n\tc := &util.Counter{}\n\tvar _ counter = c\n\tc.N++\n\tc.Count()\n}\n"
+	root := writeProject(t, map[string]string{
+		"go.mod":       "module example.com/proj\n",
+		"main.go":      main,
+		"util/util.go": "package util\n\n// Counter counts\ntype Counter struct {\n\tN Total\n}\n\n// Count returns the count\nfunc (c *Counter) Count() Total {\n\treturn c.N\n}\n\n// Total is a count\ntype Total int\n",
+	})
+	defer os.RemoveAll(root)
+
+	fm := NewFileManager(root)
+	idx := BuildTypedIndex(fm)
+	q := NewQuerier(idx, TrieFromIndex(idx))
+	check := func() {
+		idx := q.Index()
+		ref, ok := idx.Definition("main.go", 13, 4)
+		if assert.True(ok) {
+			assert.Equal([]string{"util/util.go:9"}, locations([]Reference{ref}))
+		}
+		ref, ok = idx.Definition("main.go", 12, 4)
+		if assert.True(ok) {
+			assert.Equal([]string{"util/util.go:5"}, locations([]Reference{ref}))
+		}
+		if ref, ok := idx.referenceAt("main.go", 12, 4); assert.True(ok) {
+			assert.Equal("Counter", ref.(*Field).Struct)
+		}
+		assert.Equal([]string{"util/util.go:4"}, locations(idx.Implementations("counter")))
+	}
+	check()
+
+	// main is loaded again, util isn't
+	file := filepath.Join(root, "main.go")
+	assert.NoError(ioutil.WriteFile(file, []byte(main+"\nfunc extra() {}\n"), 0644))
+	q.Update(fm.files, []string{file}, nil)
+	check()
+	assert.Len(q.Index().references["extra"], 1)
+}
+
+func TestIndexClone(t *testing.T) {
+	assert := assert.New(t)
+	root := writeProject(t, map[string]string{
+		"a.go": "package main\n\nfunc main() {\n\tshared()\n\tlocal()\n}\n\nfunc local() {}\n",
+		"b.go": "package main\n\n// shared is used by main\nfunc shared() {}\n",
+		"c.go": "package main\n\nfunc other() { shared() }\n",
+	})
+	defer os.RemoveAll(root)
+
+	fm := NewFileManager(root)
+	idx := BuildIndex(fm)
+	sharedRefs := locations(idx.references["shared"])
+	mainFn := idx.functions["main"][0]
+
+	c := idx.clone(fm)
+	a := filepath.Join(root, "a.go")
+	assert.NoError(ioutil.WriteFile(a, []byte("package main\n\nfunc main() {\n\t_ = 1\n\tshared()\n}\n"), 0644))
+	c.Update([]string{a}, []string{filepath.Join(root, "c.go")})
+
+	// the references of unchanged files are shared
+	assert.Equal(idx.idents["b.go"], c.idents["b.go"])
+	assert.Equal([]string{"a.go:5", "b.go:4"}, locations(c.references["shared"]))
+	assert.Empty(c.references["local"])
+	assert.Empty(c.references["other"])
+	assert.Len(c.docs["shared"], 1)
+
+	// while the original is left as it was
+	assert.Equal(sharedRefs, locations(idx.references["shared"]))
+	assert.Len(idx.references["local"], 2)
+	assert.Len(idx.references["other"], 1)
+	assert.Equal([]string{"shared", "local"}, mainFn.Calls)
+	assert.Len(idx.symbols[idx.symbolOf(mainFn)], 1)
+	assert.Contains(idx.idents, "c.go")
+	assert.Len(idx.filePackages["a.go"].Files, 3)
+}
+
+const fieldsSource = `package main
+
+import "strings"
+
+type Server struct {
+	port int ` + "`json:\"port\"`" + `
+	name string
+	*Config
+}
+
+type Config struct {
+	name string
+}
+
+func main() {
+	s := Server{port: 1}
+	use(s.port, s.name, s.missing)
+	var b strings.Builder
+	use(b.Len())
+	c := Config{name: "x"}
+	use(c.name)
+}
+
+func use(args ...interface{}) {}
+`
+
+func TestFields(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"go.mod":  "module example.com/proj\n",
+		"main.go": fieldsSource,
+	})
+	defer os.RemoveAll(root)
+
+	// the owner of every Field reference to the word, by line
+	fields := func(idx *Index, word string) []string {
+		owners := []string{}
+		for _, ref := range idx.references[word] {
+			if f, ok := ref.(*Field); ok {
+				owners = append(owners, fmt.Sprintf("%d:%s", f.Line, f.Struct))
+			}
+		}
+		sort.Strings(owners)
+		return owners
+	}
+
+	fm := NewFileManager(root)
+	idx := BuildIndex(fm)
+	assert := assert.New(t)
+	if assert.Len(idx.structs["Server"], 1) {
+		assert.Equal([]*StructField{
+			{Name: "port", Type: "int", Tag: `json:"port"`},
+			{Name: "name", Type: "string"},
+			{Name: "Config", Type: "*Config", Embedded: true},
+		}, idx.structs["Server"][0].Fields)
+	}
+
+	// port is only declared by Server
+	assert.Equal([]string{"16:Server", "17:Server", "6:Server"}, fields(idx, "port"))
+	// without types, a selected name declared by both structs has no owner
+	// unless the composite literal gives it away
+	assert.Equal([]string{"12:Config", "17:", "20:Config", "21:", "7:Server"}, fields(idx, "name"))
+	// no struct of the project has these
+	assert.Empty(fields(idx, "missing"))
+	assert.Empty(fields(idx, "Len"))
+
+	idx = BuildTypedIndex(fm)
+	assert.Equal([]string{"12:Config", "17:Server", "20:Config", "21:Config", "7:Server"}, fields(idx, "name"))
+	assert.Empty(fields(idx, "missing"))
+	assert.Empty(fields(idx, "Len"))
+}
+
+const kindsSource = `package main
+
+type Alias = Server
+type ID int
+type Port ID
+type Handler func()
+type Set map[string]bool
+type List []string
+type Grid [4]int
+type Queue chan int
+type Ref *Server
+type Server struct{}
+type Reader interface{}
+
+const Max = 10
+
+var global = 1
+
+func main() {
+	local := global
+	_ = local
+}
+`
+
+func TestTypeKinds(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"go.mod":  "module example.com/proj\n",
+		"main.go": kindsSource,
+	})
+	defer os.RemoveAll(root)
+
+	// the type of the declaration of the word as the UI shows it
+	kind := func(idx *Index, word string) string {
+		for _, ref := range idx.references[word] {
+			if isDeclaration(ref) {
+				return References{ref}.Format()[0].Type
+			}
+		}
+		return ""
+	}
+
+	fm := NewFileManager(root)
+	for _, typed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("typed=%t", typed), func(t *testing.T) {
+			assert := assert.New(t)
+			idx := BuildIndex(fm)
+			if typed {
+				idx = BuildTypedIndex(fm)
+			}
+
+			assert.Equal(TypeKindAlias, kind(idx, "Alias"))
+			assert.Equal(TypeKindBasic, kind(idx, "ID"))
+			assert.Equal(TypeKindFunc, kind(idx, "Handler"))
+			assert.Equal(TypeKindMap, kind(idx, "Set"))
+			assert.Equal(TypeKindSlice, kind(idx, "List"))
+			assert.Equal(TypeKindArray, kind(idx, "Grid"))
+			assert.Equal(TypeKindChan, kind(idx, "Queue"))
+			assert.Equal(TypeKindPointer, kind(idx, "Ref"))
+			assert.Equal("struct", kind(idx, "Server"))
+			assert.Equal("interface", kind(idx, "Reader"))
+			assert.Equal("constant", kind(idx, "Max"))
+			// only type information tells what Port is defined from
+			if typed {
+				assert.Equal(TypeKindBasic, kind(idx, "Port"))
+			} else {
+				assert.Equal(TypeKindNamed, kind(idx, "Port"))
+			}
+
+			// the type filters
+			decl := func(word string) Reference {
+				for _, ref := range idx.references[word] {
+					if isDeclaration(ref) {
+						return ref
+					}
+				}
+				return nil
+			}
+			assert.True(isType(decl("Alias"), ResultsAliases))
+			assert.True(isType(decl("ID"), ResultsBasicTypes))
+			assert.True(isType(decl("Handler"), ResultsFuncTypes))
+			assert.True(isType(decl("Set"), ResultsMapTypes))
+			assert.True(isType(decl("List"), ResultsSliceTypes))
+			assert.True(isType(decl("Grid"), ResultsSliceTypes))
+			assert.False(isType(decl("Queue"), ResultsSliceTypes))
+			assert.True(isType(decl("Queue"), ResultsChanTypes))
+			assert.True(isType(decl("Ref"), ResultsPointerTypes))
+			assert.Equal(!typed, isType(decl("Port"), ResultsNamedTypes))
+			assert.True(isType(decl("Reader"), ResultsInterfaces))
+			assert.False(isType(decl("Reader"), ResultsStructs))
+			assert.True(isType(decl("Max"), ResultsConstants))
+			assert.True(isType(decl("global"), ResultsGlobals))
+			assert.True(isType(decl("local"), ResultsVariables))
+			assert.False(isType(decl("local"), ResultsGlobals))
+		})
+	}
+}
+
+func TestParseFilesParallel(t *testing.T) {
+	assert := assert.New(t)
+	files := map[string]string{
+		"go.mod": "module example.com/proj\n",
+		// can't be parsed
+		"broken.go": "package main\n\nfunc {\n",
+	}
+	for i := 0; i < 50; i++ {
+		files[fmt.Sprintf("pkg%d/file%d.go", i%5, i)] = fmt.Sprintf(
+			"package pkg%d\n\n// F%d does things\nfunc F%d() {\n\tgo func() {\n\t\tF%d()\n\t}()\n}\n", i%5, i, i, (i+1)%50)
+	}
+	root := writeProject(t, files)
+	defer os.RemoveAll(root)
+	fm := NewFileManager(root)
+
+	// the ASTs come back in the order of the files
+	fset := token.NewFileSet()
+	asts := parseFiles(fset, fm.files)
+	assert.Len(asts, len(fm.files))
+	for i, f := range asts {
+		if filepath.Base(fm.files[i]) == "broken.go" {
+			assert.Nil(f)
+			continue
+		}
+		if assert.NotNil(f) {
+			assert.Equal(fm.files[i], fset.Position(f.Package).Filename)
+		}
+	}
+
+	// and index the same as parsing them one at a time
+	serial := newIndex(fm)
+	for _, file := range fm.files {
+		if f, err := parser.ParseFile(serial.fset, file, nil, parser.AllErrors|parser.ParseComments); err == nil {
+			ast.Walk(serial, f)
+		}
+	}
+	serial.finish()
+	assert.NotEmpty(formatIndex(serial))
+	assert.Equal(formatIndex(serial), formatIndex(BuildIndex(fm)))
+}
+
+// formatIndex returns every reference of the Index as the UI shows them
+func formatIndex(idx *Index) []string {
+	var results []string
+	for _, refs := range idx.references {
+		for _, res := range References(refs).Format() {
+			results = append(results, fmt.Sprintf("%+v %+v", *res, *res.Location))
+		}
+	}
+	sort.Strings(results)
+	return results
+}
+
+const scopesSource = `package main
+
+func main() {
+	a()
+	func() {
+		b()
+		func() {
+			c()
+		}()
+	}()
+	a()
+}
+
+func a() {}
+
+func b() {}
+
+func c() {}
+`
+
+func TestScopeReferences(t *testing.T) {
+	assert := assert.New(t)
+	root := writeProject(t, map[string]string{"main.go": scopesSource})
+	defer os.RemoveAll(root)
+
+	idx := BuildIndex(NewFileManager(root))
+	calls := func(name string) []string {
+		if fns := idx.functions[name]; assert.Len(fns, 1) {
+			return fns[0].Calls
+		}
+		return nil
+	}
+	// every call is credited to the innermost function only, once
+	assert.Equal([]string{"a", "main.func1", "a"}, calls("main"))
+	assert.Equal([]string{"b", "main.func1.1"}, calls("main.func1"))
+	assert.Equal([]string{"c"}, calls("main.func1.1"))
+	assert.Empty(calls("a"))
+
+	within := func(word string) []string {
+		var fns []string
+		for _, ref := range idx.references[word] {
+			if !isDeclaration(ref) {
+				fns = append(fns, ref.GetLocation().Within)
+			}
+		}
+		return fns
+	}
+	assert.Equal([]string{"main.func1.1 (main.go:7)"}, within("c"))
+	assert.ElementsMatch([]string{"main (main.go:3)", "main (main.go:3)"}, within("a"))
+
+	// scoping again doesn't add the calls twice
+	idx.scopeReferences([]string{"main.go"})
+	assert.Equal([]string{"b", "main.func1.1"}, calls("main.func1"))
+}
+
+const closuresSource = `package main
+
+type Server struct{}
+
+func (s *Server) Listen() {
+	go func() {}()
+}
+
+func main() {
+	go func() {
+		defer func() {}()
+	}()
+	f := func() {}
+	f()
+}
+
+var handler = func() {}
+`
+
+func TestClosures(t *testing.T) {
+	assert := assert.New(t)
+	root := writeProject(t, map[string]string{"main.go": closuresSource})
+	defer os.RemoveAll(root)
+
+	idx := BuildIndex(NewFileManager(root))
+	var literals []string
+	launches := make(map[string]string)
+	for _, refs := range idx.references {
+		for _, ref := range refs {
+			fn, ok := ref.(*Function)
+			if !ok || !fn.Literal && fn.IsDecl {
+				continue
+			}
+			if fn.IsDecl {
+				literals = append(literals, fn.Info())
+			} else if fn.Launch != "" {
+				launches[fn.Name] = fn.Launch
+			}
+		}
+	}
+	sort.Strings(literals)
+	// named the way the runtime does
+	assert.Equal([]string{
+		"Server.Listen.func1 (main.go:6)",
+		"glob..func1 (main.go:17)",
+		"main.func1 (main.go:10)",
+		"main.func1.1 (main.go:11)",
+		"main.func2 (main.go:13)",
+	}, literals)
+	assert.Equal(map[string]string{
+		"Listen.func1": "go",
+		"main.func1":   "go",
+		"main.func1.1": "defer",
+	}, launches)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"go/types"
+)
+
+// Implementations returns every concrete type in the project that implements
+// an interface with the given name. Interfaces with an empty method set are
+// skipped since every type implements them.
+func (x *Index) Implementations(name string) References {
+	var impls References
+	for _, iface := range x.interfaces[name] {
+		if len(iface.Methods) == 0 {
+			continue
+		}
+		for _, typ := range x.concreteTypes() {
+			if x.implements(typ, iface) {
+				impls = append(impls, typ)
+			}
+		}
+	}
+	return impls
+}
+
+// Satisfies returns every interface in the project that a type with the given
+// name implements. Interfaces with an empty method set are skipped.
+func (x *Index) Satisfies(name string) References {
+	var ifaces References
+	for _, typ := range x.concreteTypes() {
+		if referenceName(typ) != name {
+			continue
+		}
+		for _, interfaces := range x.interfaces {
+			for _, iface := range interfaces {
+				if len(iface.Methods) > 0 && x.implements(typ, iface) {
+					ifaces = append(ifaces, iface)
+				}
+			}
+		}
+	}
+	return ifaces
+}
+
+// concreteTypes returns the declarations of every type in the project that
+// can have methods, ie. structs and named types other than aliases
+func (x *Index) concreteTypes() References {
+	var refs References
+	for _, structs := range x.structs {
+		for _, s := range structs {
+			refs = append(refs, s)
+		}
+	}
+	for _, named := range x.types {
+		for _, t := range named {
+			if t.Kind != TypeKindAlias {
+				refs = append(refs, t)
+			}
+		}
+	}
+	return refs
+}
+
+// implements returns true if the type implements the interface. With type
+// information the method signatures have to match as well, and the methods of
+// the pointer type count. Without it, the method names declared on receivers
+// of the same name have to cover the method set of the interface.
+func (x *Index) implements(typ Reference, iface *Interface) bool {
+	if methods := methodsOf(typ); methods != nil && iface.methods != nil {
+		for key, sig := range iface.methods {
+			if s, ok := methods[key]; !ok || s != sig {
+				return false
+			}
+		}
+		return true
+	}
+
+	name := referenceName(typ)
+	methods := make(map[string]bool)
+	for fnName, fns := range x.functions {
+		for _, fn := range fns {
+			if fn.Reciever == name {
+				methods[fnName] = true
+			}
+		}
+	}
+	for _, m := range iface.Methods {
+		if !methods[m] {
+			return false
+		}
+	}
+	return true
+}
+
+// completeInterfaces expands the method sets of the interfaces declared in
+// the given files that embed other interfaces of the project. This is only
+// needed without type information.
+func (x *Index) completeInterfaces(files []string) {
+	for _, file := range files {
+		for _, span := range x.idents[file] {
+			if iface, ok := span.ref.(*Interface); ok {
+				iface.Methods = x.interfaceMethods(iface, map[*Interface]bool{})
+			}
+		}
+	}
+}
+
+func (x *Index) interfaceMethods(iface *Interface, seen map[*Interface]bool) []string {
+	seen[iface] = true
+	methods := iface.Methods
+	for _, name := range iface.embeds {
+		for _, embedded := range x.interfaces[name] {
+			if seen[embedded] {
+				continue
+			}
+			for _, m := range x.interfaceMethods(embedded, seen) {
+				if !hasString(methods, m) {
+					methods = append(methods, m)
+				}
+			}
+		}
+	}
+	return methods
+}
+
+func hasString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// signatures maps the methods of a type, by methodKey, to their signature
+type signatures map[string]string
+
+// methodSignatures returns the method set of a type checked object, including
+// the methods of its pointer type. The type and the interfaces it implements
+// may have been loaded at different times, or restored from the cache, which
+// doesn't give them the same objects, so methods are compared by name and
+// signature rather than with types.Implements. Returns nil without an object.
+func methodSignatures(obj types.Object) signatures {
+	if obj == nil {
+		return nil
+	}
+	typ := obj.Type()
+	if !types.IsInterface(typ) {
+		typ = types.NewPointer(typ)
+	}
+	sigs := make(signatures)
+	mset := types.NewMethodSet(typ)
+	for i := 0; i < mset.Len(); i++ {
+		m := mset.At(i).Obj()
+		sigs[methodKey(m)] = types.TypeString(m.Type(), nil)
+	}
+	return sigs
+}
+
+// methodsOf returns the method set of a type found with type information
+func methodsOf(typ Reference) signatures {
+	switch t := typ.(type) {
+	case *Struct:
+		return t.methods
+	case *NamedType:
+		return t.methods
+	}
+	return nil
+}
+
+// methodKey names a method, along with its package if it is unexported
+func methodKey(m types.Object) string {
+	if m.Exported() || m.Pkg() == nil {
+		return m.Name()
+	}
+	return m.Pkg().Path() + "." + m.Name()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const interfacesSource = `package main
+
+type Reader interface {
+	Read() string
+}
+
+type ReadCloser interface {
+	Reader
+	Close()
+}
+
+type Empty interface{}
+
+type File struct{}
+
+func (f *File) Read() string { return "" }
+
+func (f *File) Close() {}
+
+type Pipe struct{}
+
+func (p Pipe) Read() string { return "" }
+
+type Counter int
+
+func (c Counter) Read() int { return int(c) }
+`
+
+// names returns the sorted names of the References
+func names(refs References) []string {
+	words := []string{}
+	for _, ref := range refs {
+		words = append(words, referenceName(ref))
+	}
+	sort.Strings(words)
+	return words
+}
+
+func TestImplementations(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"go.mod":  "module example.com/proj\n",
+		"main.go": interfacesSource,
+	})
+	defer os.RemoveAll(root)
+
+	fm := NewFileManager(root)
+	for _, typed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("typed=%t", typed), func(t *testing.T) {
+			assert := assert.New(t)
+			idx := BuildIndex(fm)
+			if typed {
+				idx = BuildTypedIndex(fm)
+			}
+
+			// the methods of the embedded Reader count
+			assert.Equal([]string{"File"}, names(idx.Implementations("ReadCloser")))
+			assert.Equal([]string{"ReadCloser", "Reader"}, names(idx.Satisfies("File")))
+			assert.Empty(idx.Implementations("Empty"))
+			assert.Empty(idx.Implementations("Missing"))
+
+			// only the names of the methods are known without types, so the
+			// Read of Counter passes for the one of Reader
+			if typed {
+				assert.Equal([]string{"File", "Pipe"}, names(idx.Implementations("Reader")))
+				assert.Empty(idx.Satisfies("Counter"))
+			} else {
+				assert.Equal([]string{"Counter", "File", "Pipe"}, names(idx.Implementations("Reader")))
+				assert.Equal([]string{"Reader"}, names(idx.Satisfies("Counter")))
+			}
+		})
+	}
+}
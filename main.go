@@ -1,42 +1,74 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 )
 
 func main() {
+	typed := flag.Bool("types", false, "type-check packages so references resolve to the objects they use")
+	cacheDir := flag.String("cache", defaultCacheDir(), "directory to keep the index cache in, empty to disable it")
+	watch := flag.Bool("watch", false, "poll the project for changes and re-index changed files")
+	flag.Parse()
+
 	// default to current directory but if a directory is given use that one
 	// as the root for parsing and indexing .go files
 	root := "."
-	if len(os.Args) > 1 {
-		root = os.Args[1]
+	if flag.NArg() > 0 {
+		root = flag.Arg(0)
 	}
 
 	// fetch all project files
 	fmt.Println("Fetching files...")
 	fm := NewFileManager(root)
+	var w *Watcher
+	if *watch {
+		w = NewWatcher(fm, DefaultWatchInterval)
+	}
 
 	// construct the index
 	fmt.Println("Building index...")
-	idx := BuildIndex(fm)
+	var idx *Index
+	var trie *Trie
+	switch {
+	case *cacheDir != "":
+		idx, trie = BuildCachedIndex(fm, *typed, CachePath(*cacheDir, root, *typed))
+	case *typed:
+		idx = BuildTypedIndex(fm)
+	default:
+		idx = BuildIndex(fm)
+	}
 
 	// build the function tree
 	fmt.Println("Building callstack...")
-	cs := idx.Functions().BuildCallStack()
-	if err := cs.Write("static/tree.json"); err != nil {
+	if err := writeCallStack(idx); err != nil {
 		fmt.Printf("Error creating callstack json: %s\n", err)
 		os.Exit(1)
 	}
 
 	// build the trie
-	fmt.Println("Building prefix tree...")
-	trie := TrieFromIndex(idx)
+	if trie == nil {
+		fmt.Println("Building prefix tree...")
+		trie = TrieFromIndex(idx)
+	}
 
 	// init the querier
 	fmt.Println("Initializing search...")
 	q := NewQuerier(idx, trie)
 
+	// keep the index up to date with changes to the project
+	if w != nil {
+		go w.Watch(func(files, changed, removed []string) {
+			fmt.Printf("Re-indexing %d changed and %d removed files...\n", len(changed), len(removed))
+			q.Update(files, changed, removed)
+			if err := writeCallStack(q.Index()); err != nil {
+				fmt.Printf("Error creating callstack json: %s\n", err)
+			}
+		})
+	}
+
 	// start the http server listening, default port is :8080
 	s := NewServer(q, fm)
 	if err := s.Listen(); err != nil {
@@ -44,3 +76,19 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// writeCallStack builds the function tree of the Index for the callstack view
+func writeCallStack(idx *Index) error {
+	cs := idx.Functions().BuildCallStack()
+	return cs.Write("static/tree.json")
+}
+
+// defaultCacheDir returns the user's cache directory for go-search, or an empty
+// string to disable the cache if there is none
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "go-search")
+}
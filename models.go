@@ -3,13 +3,21 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"go/types"
 )
 
 // Location defines where something exists in the project
 type Location struct {
-	File   string `json:"file"`
-	Line   int    `json:"line"`
-	Within string `json:"within"` //function identifier that wraps the reference if any
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Column     int    `json:"column"` // byte column, starting at 1
+	Offset     int    `json:"offset"` // byte offset in the file, starting at 0
+	EndLine    int    `json:"end_line"`
+	EndColumn  int    `json:"end_column"` // just past the end
+	EndOffset  int    `json:"end_offset"`
+	Within     string `json:"within"` //function identifier that wraps the reference if any
+	Package    string `json:"package"`
+	ImportPath string `json:"import_path"`
 }
 
 func (l *Location) String() string {
@@ -19,14 +27,17 @@ func (l *Location) String() string {
 // Reference is an interface type to represent a word in a Go file
 type Reference interface {
 	GetLocation() *Location
+	GetObject() types.Object
 	ToJSON() ([]byte, error)
 }
 
 // Variable implements Reference and represents a variable type in the Go code
 type Variable struct {
 	*Location `'json:"location"`
-	Name      string `json:"name"`
-	IsDecl    bool   `json:"is_decl"`
+	Name      string       `json:"name"`
+	IsDecl    bool         `json:"is_decl"`
+	Object    types.Object `json:"-"` // resolved object, nil without type info
+	global    bool         // resolved to a package-level variable
 }
 
 // GetLocation returns the Location of the Variable
@@ -34,20 +45,66 @@ func (v *Variable) GetLocation() *Location {
 	return v.Location
 }
 
+// GetObject returns the object the Variable resolves to
+func (v *Variable) GetObject() types.Object {
+	return v.Object
+}
+
 // ToJSON marshalls the Variable to JSON
 func (v *Variable) ToJSON() ([]byte, error) {
 	return json.Marshal(v)
 }
 
+// IsGlobal returns true if the Variable is declared at package level. Without
+// type information only declarations outside of any function can be told apart.
+func (v *Variable) IsGlobal() bool {
+	if v.Object != nil || v.global {
+		return v.global
+	}
+	return v.IsDecl && v.Within == ""
+}
+
+// Constant implements Reference and represents a constant in the Go code.
+// Value is the evaluated value if there is type information, otherwise the
+// expression the constant is declared with.
+type Constant struct {
+	*Location `json:"location"`
+	Name      string       `json:"name"`
+	Value     string       `json:"value"`
+	IsDecl    bool         `json:"is_decl"`
+	Object    types.Object `json:"-"` // resolved object, nil without type info
+}
+
+// GetLocation returns the Location of the Constant
+func (c *Constant) GetLocation() *Location {
+	return c.Location
+}
+
+// GetObject returns the object the Constant resolves to
+func (c *Constant) GetObject() types.Object {
+	return c.Object
+}
+
+// ToJSON marshalls the Constant to JSON
+func (c *Constant) ToJSON() ([]byte, error) {
+	return json.Marshal(c)
+}
+
 // Function implements Reference and represents a function type in the Go code.
 // This can be a function call or function declaration.
 type Function struct {
 	*Location `json:"location"`
-	Name      string   `json:"name"`
-	Reciever  string   `json:"receiver"`
-	Size      int      `json:"size"`
-	IsDecl    bool     `json:"is_decl"`
-	Calls     []string `json:"fn_calls"`
+	Name      string       `json:"name"`
+	Doc       string       `json:"doc,omitempty"`
+	Reciever  string       `json:"receiver"`
+	Size      int          `json:"size"`
+	IsDecl    bool         `json:"is_decl"`
+	Calls     []string     `json:"fn_calls"`
+	Literal   bool         `json:"literal,omitempty"` // a function literal, eg. main.func1
+	Launch    string       `json:"launch,omitempty"`  // "go" or "defer" for calls made by those statements
+	Object    types.Object `json:"-"`                 // resolved object, nil without type info
+	calls     []*Function  // the call references behind Calls
+	recvType  string       // type of the receiver of a method call, with type info
 }
 
 // GetLocation returns the Location of the Function
@@ -55,6 +112,11 @@ func (f *Function) GetLocation() *Location {
 	return f.Location
 }
 
+// GetObject returns the object the Function resolves to
+func (f *Function) GetObject() types.Object {
+	return f.Object
+}
+
 // ToJSON marshalls the Function to JSON
 func (f *Function) ToJSON() ([]byte, error) {
 	return json.Marshal(f)
@@ -70,7 +132,8 @@ func (f *Function) Info() string {
 }
 
 // Wraps returns true if the given location is "wrapped" by the function, eg.
-// it exists within the function body. Returns false otherwise.
+// it exists between its func keyword and the end of its body. Returns false
+// otherwise.
 func (f *Function) Wraps(loc *Location) bool {
 	if !f.IsDecl {
 		// not a function body
@@ -80,14 +143,25 @@ func (f *Function) Wraps(loc *Location) bool {
 		// not even in the same file bro
 		return false
 	}
-	return loc.Line >= f.Line && loc.Line <= f.Line+f.Size
+	return loc.Offset >= f.Offset && loc.Offset < f.EndOffset
 }
 
 // Struct implements Reference and represents a struct type in the Go code.
 type Struct struct {
 	*Location `json:"location"`
-	Name      string   `json:"name"`
-	Fields    []string `json:"fields"` // XXX: not implemented
+	Name      string         `json:"name"`
+	Doc       string         `json:"doc,omitempty"`
+	Fields    []*StructField `json:"fields"`
+	Object    types.Object   `json:"-"` // resolved object, nil without type info
+	methods   signatures     // method set, nil without type info
+}
+
+// StructField describes a field declared by a Struct
+type StructField struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Tag      string `json:"tag"`
+	Embedded bool   `json:"embedded"`
 }
 
 // GetLocation returns the Location of the Struct
@@ -95,11 +169,153 @@ func (s *Struct) GetLocation() *Location {
 	return s.Location
 }
 
+// GetObject returns the object the Struct resolves to
+func (s *Struct) GetObject() types.Object {
+	return s.Object
+}
+
 // ToJSON marshalls the Struct to JSON
 func (s *Struct) ToJSON() ([]byte, error) {
 	return json.Marshal(s)
 }
 
+// Kinds of NamedType, named for how they are shown in results
+const (
+	TypeKindAlias   = "alias"
+	TypeKindBasic   = "basic type"
+	TypeKindFunc    = "func type"
+	TypeKindMap     = "map type"
+	TypeKindSlice   = "slice type"
+	TypeKindArray   = "array type"
+	TypeKindChan    = "chan type"
+	TypeKindPointer = "pointer type"
+	TypeKindNamed   = "named type" // defined from another named type
+)
+
+// NamedType implements Reference and represents a type declaration in the Go
+// code that is neither a struct nor an interface, eg. an alias, a func type or
+// a named map type.
+type NamedType struct {
+	*Location `json:"location"`
+	Name      string       `json:"name"`
+	Kind      string       `json:"kind"`
+	Type      string       `json:"type"` // the type expression it is declared as
+	Doc       string       `json:"doc,omitempty"`
+	Object    types.Object `json:"-"` // resolved object, nil without type info
+	methods   signatures   // method set, nil without type info
+}
+
+// GetLocation returns the Location of the NamedType
+func (t *NamedType) GetLocation() *Location {
+	return t.Location
+}
+
+// GetObject returns the object the NamedType resolves to
+func (t *NamedType) GetObject() types.Object {
+	return t.Object
+}
+
+// ToJSON marshalls the NamedType to JSON
+func (t *NamedType) ToJSON() ([]byte, error) {
+	return json.Marshal(t)
+}
+
+// Field implements Reference and represents a struct field in the Go code.
+// This can be the field declaration or a selector expression using the field.
+type Field struct {
+	*Location `json:"location"`
+	Name      string       `json:"name"`
+	Struct    string       `json:"struct"` // struct owning the field, if known
+	IsDecl    bool         `json:"is_decl"`
+	Object    types.Object `json:"-"` // resolved object, nil without type info
+}
+
+// GetLocation returns the Location of the Field
+func (f *Field) GetLocation() *Location {
+	return f.Location
+}
+
+// GetObject returns the object the Field resolves to
+func (f *Field) GetObject() types.Object {
+	return f.Object
+}
+
+// ToJSON marshalls the Field to JSON
+func (f *Field) ToJSON() ([]byte, error) {
+	return json.Marshal(f)
+}
+
+// Interface implements Reference and represents an interface type in the Go
+// code. Methods holds the names in its method set.
+type Interface struct {
+	*Location `json:"location"`
+	Name      string       `json:"name"`
+	Methods   []string     `json:"methods"`
+	Doc       string       `json:"doc,omitempty"`
+	Object    types.Object `json:"-"` // resolved object, nil without type info
+	embeds    []string     // names of embedded interfaces, without type info
+	methods   signatures   // method set, nil without type info
+}
+
+// GetLocation returns the Location of the Interface
+func (i *Interface) GetLocation() *Location {
+	return i.Location
+}
+
+// GetObject returns the object the Interface resolves to
+func (i *Interface) GetObject() types.Object {
+	return i.Object
+}
+
+// ToJSON marshalls the Interface to JSON
+func (i *Interface) ToJSON() ([]byte, error) {
+	return json.Marshal(i)
+}
+
+// StringLiteral implements Reference and represents a string literal in the Go
+// code, eg. an error or log message
+type StringLiteral struct {
+	*Location `json:"location"`
+	Value     string `json:"value"` // unquoted
+}
+
+// GetLocation returns the Location of the StringLiteral
+func (l *StringLiteral) GetLocation() *Location {
+	return l.Location
+}
+
+// GetObject returns nil since a literal does not resolve to an object
+func (l *StringLiteral) GetObject() types.Object {
+	return nil
+}
+
+// ToJSON marshalls the StringLiteral to JSON
+func (l *StringLiteral) ToJSON() ([]byte, error) {
+	return json.Marshal(l)
+}
+
+// SourceLine implements Reference and represents a line of source matching a
+// search, with the Location of the match on it
+type SourceLine struct {
+	*Location `json:"location"`
+	Text      string `json:"text"`
+}
+
+// GetLocation returns the Location of the match on the SourceLine
+func (l *SourceLine) GetLocation() *Location {
+	return l.Location
+}
+
+// GetObject returns nil since a line does not resolve to an object
+func (l *SourceLine) GetObject() types.Object {
+	return nil
+}
+
+// ToJSON marshalls the SourceLine to JSON
+func (l *SourceLine) ToJSON() ([]byte, error) {
+	return json.Marshal(l)
+}
+
 // References is a list of Reference interfaces
 type References []Reference
 
@@ -109,68 +325,73 @@ type SmartSort []Reference
 
 // This sort implementation is a smarter way to rank results. It assumes the
 // following characteristics about code discovery:
-// 1. function references are more important than variable or struct references
+// 1. function references are more important than any other reference
 // 2. function declarations are more important than function calls
 // 3. a function is more important if it is invoked more
-// 4. structs are more interesting than variables
-// 5. between variables, if one reference is a declaration, it is more important
-//		otherwise use the names of the variable to break the tie.
+// 4. type declarations are more interesting than constants, constants are more
+//		interesting than fields, and fields are more interesting than variables
+// 5. between variables, fields or constants, if one reference is a
+//		declaration, it is more important otherwise use the names of the
+//		variable to break the tie.
 func (r SmartSort) Len() int      { return len(r) }
 func (r SmartSort) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
 func (r SmartSort) Less(i, j int) bool {
+	if ki, kj := kindRank(r[i]), kindRank(r[j]); ki != kj {
+		return ki < kj
+	}
+
+	// both references are of the same kind
 	switch r1 := r[i].(type) {
 	case *Function:
-		if r2, ok := r[j].(*Function); ok {
-			// if both results are functions, favor the declaration
-			if r1.IsDecl && r2.IsDecl {
-				// if both are declarations, favor the one used more
-				return len(r1.Calls) > len(r2.Calls)
-			}
-			// r1 < r2 if r2 is a function declaration
-			return r2.IsDecl
+		r2, _ := r[j].(*Function)
+		// if both results are functions, favor the declaration
+		if r1.IsDecl && r2.IsDecl {
+			// if both are declarations, favor the one used more
+			return len(r1.Calls) > len(r2.Calls)
 		}
-		// r2 < r1 since r1 is a function reference
-		return false
-	case *Variable:
-		if _, ok := r[j].(*Function); ok {
-			// r1 < r2 because r2 is a function ref
-			return true
-		}
-		if _, ok := r[j].(*Struct); ok {
-			// r1 < r2 because r2 is a struct ref
-			return true
-		}
-		// both are variables, break tie with name
-		r2, _ := r[j].(*Variable)
-		if r1.Name == r2.Name {
+		// r1 < r2 if r2 is a function declaration
+		return r2.IsDecl
+	case *Variable, *Field, *Constant:
+		if referenceName(r1) == referenceName(r[j]) {
 			// same name too!
-			return r2.IsDecl
-		}
-		return r1.Name > r2.Name
-	case *Struct:
-		if _, ok := r[j].(*Function); ok {
-			// r1 < r2 because r2 is a function ref
-			return true
-		}
-		if _, ok := r[j].(*Variable); ok {
-			// r1 > r2 because r2 is only a variable
-			return false
+			return isDeclaration(r[j])
 		}
-		// both are structs, break tie with name
-		r2, _ := r[j].(*Struct)
-		return r1.Name > r2.Name
+		return referenceName(r1) > referenceName(r[j])
+	case *Struct, *Interface, *NamedType:
+		// both are types, break tie with name
+		return referenceName(r1) > referenceName(r[j])
 	}
 	// we should never hit this
 	return true
 }
 
+// kindRank orders the kinds of references by how interesting they are, the
+// higher the rank the more interesting.
+func kindRank(ref Reference) int {
+	switch ref.(type) {
+	case *Function:
+		return 4
+	case *Struct, *Interface, *NamedType:
+		return 3
+	case *Constant:
+		return 2
+	case *Field:
+		return 1
+	}
+	return 0
+}
+
 // Result is the JSON response type for a reference in the code
 type Result struct {
-	Word      string `json:"word"`
-	Type      string `json:"type"`
-	Reference string `json:"reference"`
-	IsDecl    string `json:"is_decl"`
-	WithinFn  string `json:"within_fn"`
+	Word      string    `json:"word"`
+	Type      string    `json:"type"`
+	Reference string    `json:"reference"`
+	IsDecl    string    `json:"is_decl"`
+	WithinFn  string    `json:"within_fn"`
+	Package   string    `json:"package"`
+	Value     string    `json:"value,omitempty"` // only set for constants
+	Doc       string    `json:"doc,omitempty"`   // only set for declarations
+	Location  *Location `json:"location"`
 }
 
 // Format code References to be Result types
@@ -201,12 +422,38 @@ func (r References) Format() []*Result {
 				IsDecl:    "no",
 				WithinFn:  "global",
 			}
+			if d.IsGlobal() {
+				res.Type = "package var"
+			}
+			if d.IsDecl {
+				res.IsDecl = "yes"
+			}
+			if d.Within != "" {
+				res.WithinFn = d.Within
+			}
+		case *Constant:
+			res = &Result{
+				Word:      d.Name,
+				Type:      "constant",
+				Reference: d.Location.String(),
+				IsDecl:    "no",
+				WithinFn:  "global",
+				Value:     d.Value,
+			}
 			if d.IsDecl {
 				res.IsDecl = "yes"
 			}
 			if d.Within != "" {
 				res.WithinFn = d.Within
 			}
+		case *NamedType:
+			res = &Result{
+				Word:      d.Name,
+				Type:      d.Kind,
+				Reference: d.Location.String(),
+				IsDecl:    "yes",
+				WithinFn:  "global",
+			}
 		case *Struct:
 			res = &Result{
 				Word:      d.Name,
@@ -215,10 +462,60 @@ func (r References) Format() []*Result {
 				IsDecl:    "yes",
 				WithinFn:  "global",
 			}
+		case *Field:
+			res = &Result{
+				Word:      d.Name,
+				Type:      "field",
+				Reference: d.Location.String(),
+				IsDecl:    "no",
+				WithinFn:  "global",
+			}
+			if d.Struct != "" {
+				res.Word = fmt.Sprintf("%s.%s", d.Struct, d.Name)
+			}
+			if d.IsDecl {
+				res.IsDecl = "yes"
+			}
+			if d.Within != "" {
+				res.WithinFn = d.Within
+			}
+		case *Interface:
+			res = &Result{
+				Word:      d.Name,
+				Type:      "interface",
+				Reference: d.Location.String(),
+				IsDecl:    "yes",
+				WithinFn:  "global",
+			}
+		case *StringLiteral:
+			res = &Result{
+				Word:      d.Value,
+				Type:      "string",
+				Reference: d.Location.String(),
+				IsDecl:    "no",
+				WithinFn:  "global",
+			}
+			if d.Within != "" {
+				res.WithinFn = d.Within
+			}
+		case *SourceLine:
+			res = &Result{
+				Word:      d.Text,
+				Type:      "line",
+				Reference: d.Location.String(),
+				IsDecl:    "no",
+				WithinFn:  "global",
+			}
+			if d.Within != "" {
+				res.WithinFn = d.Within
+			}
 		default:
 			fmt.Printf("Unknown Reference type %v\n", d)
 		}
 		if res != nil {
+			res.Package = ref.GetLocation().ImportPath
+			res.Location = ref.GetLocation()
+			res.Doc = docOf(ref)
 			results = append(results, res)
 		}
 	}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Package describes a package of the project: the files it is made of, the
+// packages it imports and the packages of the project importing it.
+type Package struct {
+	Name       string   `json:"name"`
+	ImportPath string   `json:"import_path"`
+	Files      []string `json:"files"`
+	Imports    []string `json:"imports"`
+	Importers  []string `json:"importers"`
+}
+
+// Packages returns every package of the project sorted by import path
+func (x *Index) Packages() []*Package {
+	pkgs := make([]*Package, 0, len(x.packages))
+	for _, pkg := range x.packages {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].ImportPath < pkgs[j].ImportPath })
+	return pkgs
+}
+
+// location returns the Location of the given range in the project, along with
+// the package of the file it is in
+func (x *Index) location(start, end token.Pos) *Location {
+	pos := x.fset.Position(start)
+	posEnd := x.fset.Position(end)
+	relPath := x.fileMgr.Rel(pos.Filename)
+	loc := &Location{
+		File:      relPath,
+		Line:      pos.Line,
+		Column:    pos.Column,
+		Offset:    pos.Offset,
+		EndLine:   posEnd.Line,
+		EndColumn: posEnd.Column,
+		EndOffset: posEnd.Offset,
+	}
+	if pkg, ok := x.filePackages[relPath]; ok {
+		loc.Package = pkg.Name
+		loc.ImportPath = pkg.ImportPath
+	}
+	return loc
+}
+
+// addFile records the file with its package and imports. It must be called
+// before any reference in the file is added.
+func (x *Index) addFile(f *ast.File) {
+	pos := x.fset.Position(f.Package)
+
+	// external test packages share the directory of the package they test
+	importPath := x.fileMgr.ImportPath(filepath.Dir(pos.Filename))
+	if strings.HasSuffix(f.Name.Name, "_test") {
+		importPath += "_test"
+	}
+
+	var imports []string
+	for _, imp := range f.Imports {
+		if path, err := strconv.Unquote(imp.Path.Value); err == nil {
+			imports = append(imports, path)
+		}
+	}
+	x.addPackageFile(x.fileMgr.Rel(pos.Filename), f.Name.Name, importPath, imports)
+}
+
+// addPackageFile records a file of the package with the given import path
+func (x *Index) addPackageFile(relPath, name, importPath string, imports []string) {
+	pkg, ok := x.packages[importPath]
+	if !ok {
+		pkg = &Package{
+			Name:       name,
+			ImportPath: importPath,
+		}
+		x.packages[importPath] = pkg
+	}
+	pkg.Files = append(pkg.Files, relPath)
+	for _, path := range imports {
+		if !hasString(pkg.Imports, path) {
+			pkg.Imports = append(pkg.Imports, path)
+		}
+	}
+	x.filePackages[relPath] = pkg
+	x.fileImports[relPath] = imports
+}
+
+// removePackageFile drops the file from its package, and the package once it
+// has no files left
+func (x *Index) removePackageFile(relPath string) {
+	pkg, ok := x.filePackages[relPath]
+	if !ok {
+		return
+	}
+	delete(x.filePackages, relPath)
+	delete(x.fileImports, relPath)
+
+	files := pkg.Files[:0]
+	for _, f := range pkg.Files {
+		if f != relPath {
+			files = append(files, f)
+		}
+	}
+	pkg.Files = files
+	if len(pkg.Files) == 0 {
+		delete(x.packages, pkg.ImportPath)
+		return
+	}
+
+	// the imports of the remaining files
+	pkg.Imports = nil
+	for _, f := range pkg.Files {
+		for _, path := range x.fileImports[f] {
+			if !hasString(pkg.Imports, path) {
+				pkg.Imports = append(pkg.Imports, path)
+			}
+		}
+	}
+}
+
+// linkPackages fills in the importers of every package once all of the files
+// are known
+func (x *Index) linkPackages() {
+	for _, pkg := range x.packages {
+		pkg.Importers = nil
+	}
+	for _, pkg := range x.packages {
+		sort.Strings(pkg.Imports)
+		for _, path := range pkg.Imports {
+			if imported, ok := x.localPackage(path); ok {
+				imported.Importers = append(imported.Importers, pkg.ImportPath)
+			}
+		}
+	}
+	for _, pkg := range x.packages {
+		sort.Strings(pkg.Importers)
+	}
+}
+
+// localPackage returns the package of the project with the given import path
+// and true if there is one, false otherwise
+func (x *Index) localPackage(importPath string) (*Package, bool) {
+	if pkg, ok := x.packages[importPath]; ok {
+		return pkg, true
+	}
+	return nil, false
+}
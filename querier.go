@@ -1,7 +1,11 @@
 package main
 
 import (
+	"fmt"
+	"regexp"
 	"sort"
+	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -15,63 +19,228 @@ const (
 	ResultsStructs = "structs"
 	// ResultsVariables filters on variables
 	ResultsVariables = "variables"
+	// ResultsInterfaces filters on interfaces
+	ResultsInterfaces = "interfaces"
+	// ResultsFields filters on struct fields
+	ResultsFields = "fields"
+	// ResultsConstants filters on constants
+	ResultsConstants = "constants"
+	// ResultsGlobals filters on package-level variables
+	ResultsGlobals = "package vars"
+	// ResultsAliases filters on type aliases
+	ResultsAliases = "aliases"
+	// ResultsBasicTypes filters on types defined from a basic type
+	ResultsBasicTypes = "basic types"
+	// ResultsFuncTypes filters on func types
+	ResultsFuncTypes = "func types"
+	// ResultsMapTypes filters on map types
+	ResultsMapTypes = "map types"
+	// ResultsSliceTypes filters on slice and array types
+	ResultsSliceTypes = "slice types"
+	// ResultsChanTypes filters on channel types
+	ResultsChanTypes = "chan types"
+	// ResultsPointerTypes filters on pointer types
+	ResultsPointerTypes = "pointer types"
+	// ResultsNamedTypes filters on types defined from another named type
+	ResultsNamedTypes = "named types"
+	// ResultsStrings filters on string literals
+	ResultsStrings = "strings"
+	// ResultsLines filters on lines of source
+	ResultsLines = "lines"
 
 	// DefaultResultsLimit defines the number of results to return for query
 	DefaultResultsLimit = 10
 )
 
+const (
+	// Search modes for queries
+
+	// ModePrefix matches the words starting with the query
+	ModePrefix = "prefix"
+	// ModeAbbrev matches the words the query abbreviates, eg. BCS for
+	// BuildCallStack
+	ModeAbbrev = "abbrev"
+	// ModeDocs matches declarations by the words in their doc comments
+	ModeDocs = "docs"
+	// ModeFold matches the words starting with the query, ignoring case
+	ModeFold = "fold"
+	// ModeSubstring matches the words containing the query anywhere
+	ModeSubstring = "substring"
+	// ModeFuzzy matches the words starting with something close to the query,
+	// allowing for typos
+	ModeFuzzy = "fuzzy"
+	// ModeRegex matches the words, or with TargetSource the lines of source,
+	// against the query as a regular expression
+	ModeRegex = "regex"
+	// ModeStrings matches string literals against a message, eg. one copied
+	// from a log
+	ModeStrings = "strings"
+)
+
+const (
+	// What ModeRegex searches
+
+	// TargetSymbols searches the words of the Index
+	TargetSymbols = "symbols"
+	// TargetSource searches the source of the project, line by line
+	TargetSource = "source"
+)
+
 // QueryOptions defines filters and other options for querying
 type QueryOptions struct {
-	wtype string
-	file  string
-	limit int
+	mode   string
+	target string // what ModeRegex searches
+	wtype  string
+	file   string
+	pkg    string // package name or import path
+	// function the reference is used in and receiver of the method, eg.
+	// Querier.Query and *Index, or empty for any
+	within   string
+	receiver string
+	limit    int
+	// field filters of a structured query, see ParseQuery
+	filters []queryFilter
 }
 
 // DefaultQueryOptions returns default settings for QueryOptions which is no
 // filtering and a result limit 10
 func DefaultQueryOptions() *QueryOptions {
 	return &QueryOptions{
-		wtype: ResultsAll,
-		file:  ResultsAll,
-		limit: DefaultResultsLimit,
+		mode:   ModePrefix,
+		target: TargetSymbols,
+		wtype:  ResultsAll,
+		file:   ResultsAll,
+		pkg:    ResultsAll,
+		limit:  DefaultResultsLimit,
 	}
 }
 
 // Querier manages the logic for returning search results
 type Querier struct {
-	idx  *Index
-	trie *Trie
+	mu       sync.Mutex   // serializes updates
+	snapshot atomic.Value // *Snapshot currently served
 }
 
 // NewQuerier returns a Querier object initialized with an Index and a Trie
 func NewQuerier(idx *Index, trie *Trie) *Querier {
-	return &Querier{
-		idx,
-		trie,
+	q := &Querier{}
+	q.snapshot.Store(&Snapshot{
+		idx:      idx,
+		trie:     trie,
+		folded:   FoldedTrieFromIndex(idx),
+		suffixes: SuffixArrayFromIndex(idx),
+		abbrevs:  AbbreviationsFromIndex(idx),
+		trigrams: TrigramIndexFromFiles(idx.fileMgr.files),
+	})
+	return q
+}
+
+// Snapshot returns the Index and word indexes currently served. They must not be
+// modified.
+func (q *Querier) Snapshot() *Snapshot {
+	return q.snapshot.Load().(*Snapshot)
+}
+
+// Index returns the Index currently served
+func (q *Querier) Index() *Index {
+	return q.Snapshot().idx
+}
+
+// Update re-indexes the files that were added or changed and drops the files
+// that were removed, then patches the Tries and Abbreviations so they hold
+// exactly the words with references again. The SuffixArray is rebuilt if the
+// words changed, and the source of the changed files is indexed again. files
+// is every file now in the project. The update is made on
+// a copy of the current Snapshot, which replaces it once it is done, so queries
+// are never blocked or served a half updated Index.
+func (q *Querier) Update(files, changed, removed []string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	cur := q.Snapshot()
+	fm := *cur.idx.fileMgr
+	fm.files = files
+	idx := cur.idx.clone(&fm)
+	trie := cur.trie.clone()
+	folded := cur.folded.clone()
+	abbrevs := cur.abbrevs.clone()
+
+	wordsChanged := false
+	for _, word := range idx.Update(changed, removed) {
+		_, indexed := idx.references[word]
+		if inTrie := trie.Contains(word); indexed && !inTrie {
+			trie.Insert(word)
+			folded.Insert(word)
+			abbrevs.Insert(word)
+			wordsChanged = true
+		} else if !indexed && inTrie {
+			trie.Delete(word)
+			folded.Delete(word)
+			abbrevs.Delete(word)
+			wordsChanged = true
+		}
+	}
+
+	trigrams := cur.trigrams.clone()
+	for _, file := range removed {
+		trigrams.Remove(file)
+	}
+	for _, file := range changed {
+		if err := trigrams.AddFile(file); err != nil {
+			fmt.Printf("could not index the source of %s: %v\n", file, err)
+		}
+	}
+
+	suffixes := cur.suffixes
+	if wordsChanged {
+		suffixes = SuffixArrayFromIndex(idx)
 	}
+	q.snapshot.Store(&Snapshot{
+		idx:      idx,
+		trie:     trie,
+		folded:   folded,
+		suffixes: suffixes,
+		abbrevs:  abbrevs,
+		trigrams: trigrams,
+	})
 }
 
 // Query runs a query for the input and returns a list of References
 func (q *Querier) Query(input string, opts *QueryOptions) References {
-	n, ok := q.trie.Find(input)
-	if !ok {
-		return nil
-	}
+	snap := q.Snapshot()
 
 	var results []Reference
-	words := n.Prefixes()
-	for _, w := range words {
-		w = input + w
-		refs, ok := q.idx.ReferencesByWord(w)
-		if !ok {
-			continue
+	switch opts.mode {
+	case ModeAbbrev:
+		results = snap.abbrevMatches(input)
+	case ModeDocs:
+		results = snap.idx.SearchDocs(input)
+	case ModeFold:
+		results = snap.foldedMatches(input)
+	case ModeSubstring:
+		results = snap.substringMatches(input)
+	case ModeFuzzy:
+		results = snap.fuzzyMatches(input)
+	case ModeRegex:
+		re, err := regexp.Compile(input)
+		if err != nil {
+			return nil
 		}
-		results = append(results, refs...)
+		if opts.target == TargetSource {
+			results = snap.sourceMatches(re)
+		} else {
+			results = snap.regexMatches(re)
+		}
+	case ModeStrings:
+		results = snap.idx.SearchStrings(input)
+	default:
+		results = snap.prefixMatches(input)
 	}
 
 	// filter if needed
 	resultsFiltered := results
-	if opts.file != ResultsAll || opts.wtype != ResultsAll {
+	if opts.file != ResultsAll || opts.wtype != ResultsAll || opts.pkg != ResultsAll ||
+		opts.within != "" || opts.receiver != "" || len(opts.filters) > 0 {
 		resultsFiltered = []Reference{}
 		for _, res := range results {
 			if isMatch(res, opts) {
@@ -80,7 +249,10 @@ func (q *Querier) Query(input string, opts *QueryOptions) References {
 		}
 	}
 
-	sort.Sort(sort.Reverse(SmartSort(resultsFiltered)))
+	// abbreviation, doc, fuzzy, regex and string matches are already ranked by how well they match
+	if opts.mode == ModePrefix || opts.mode == ModeFold || opts.mode == ModeSubstring {
+		sort.Sort(sort.Reverse(SmartSort(resultsFiltered)))
+	}
 
 	if len(resultsFiltered) > opts.limit {
 		resultsFiltered = resultsFiltered[:opts.limit]
@@ -89,27 +261,166 @@ func (q *Querier) Query(input string, opts *QueryOptions) References {
 	return resultsFiltered
 }
 
+// prefixMatches returns the references to every word starting with the input
+func (s *Snapshot) prefixMatches(input string) []Reference {
+	n, ok := s.trie.Find(input)
+	if !ok {
+		return nil
+	}
+
+	var results []Reference
+	words := n.Prefixes()
+	for _, w := range words {
+		w = input + w
+		refs, ok := s.idx.ReferencesByWord(w)
+		if !ok {
+			continue
+		}
+		results = append(results, refs...)
+	}
+	return results
+}
+
+// foldedMatches returns the references to every word starting with the input,
+// ignoring case
+func (s *Snapshot) foldedMatches(input string) []Reference {
+	var results []Reference
+	for _, w := range s.folded.Find(input) {
+		refs, _ := s.idx.ReferencesByWord(w)
+		results = append(results, refs...)
+	}
+	return results
+}
+
+// substringMatches returns the references to every word containing the input
+func (s *Snapshot) substringMatches(input string) []Reference {
+	var results []Reference
+	for _, w := range s.suffixes.Lookup(input) {
+		refs, _ := s.idx.ReferencesByWord(w)
+		results = append(results, refs...)
+	}
+	return results
+}
+
+// fuzzyMatches returns the references to every word starting with something
+// close to the input, the closest first
+func (s *Snapshot) fuzzyMatches(input string) []Reference {
+	distances := make(map[Reference]int)
+	var results []Reference
+	for word, dist := range s.trie.FuzzyFind(input, fuzzyDistance(input)) {
+		refs, _ := s.idx.ReferencesByWord(word)
+		for _, ref := range refs {
+			distances[ref] = dist
+		}
+		results = append(results, refs...)
+	}
+
+	sort.Sort(sort.Reverse(SmartSort(results)))
+	sort.SliceStable(results, func(i, j int) bool {
+		return distances[results[i]] < distances[results[j]]
+	})
+	return results
+}
+
+// abbrevMatches returns the references to every word the input abbreviates,
+// the ones with the fewest segments left out first
+func (s *Snapshot) abbrevMatches(input string) []Reference {
+	left := make(map[Reference]int)
+	var results []Reference
+	for word, n := range s.abbrevs.Find(input) {
+		refs, _ := s.idx.ReferencesByWord(word)
+		for _, ref := range refs {
+			left[ref] = n
+		}
+		results = append(results, refs...)
+	}
+
+	sort.Sort(sort.Reverse(SmartSort(results)))
+	sort.SliceStable(results, func(i, j int) bool {
+		return left[results[i]] < left[results[j]]
+	})
+	return results
+}
+
+// fuzzyDistance returns how many typos to allow for in the input. Short inputs
+// are close to too many words to allow for any.
+func fuzzyDistance(input string) int {
+	switch n := len([]rune(input)); {
+	case n < 3:
+		return 0
+	case n < 6:
+		return 1
+	}
+	return 2
+}
+
 // returns true if the reference is a match on the filter and false otherwise.
 // References that do not match are filtered out.
 func isMatch(ref Reference, opts *QueryOptions) bool {
-	if opts.wtype != ResultsAll {
-		// filter on type
-		switch ref.(type) {
-		case *Function:
-			if opts.wtype != ResultsFunctions {
-				return false
-			}
-		case *Variable:
-			if opts.wtype != ResultsVariables {
-				return false
-			}
-		case *Struct:
-			if opts.wtype != ResultsStructs {
-				return false
-			}
-		}
+	if opts.wtype != ResultsAll && !isType(ref, opts.wtype) {
+		return false
 	}
 
-	// filter on file location
-	return opts.file == ResultsAll || ref.GetLocation().File == opts.file
+	// filter on package
+	loc := ref.GetLocation()
+	if opts.pkg != ResultsAll && loc.Package != opts.pkg && loc.ImportPath != opts.pkg {
+		return false
+	}
+
+	// filter on file location, by path, directory or glob pattern
+	if opts.file != ResultsAll && !fileMatches(loc.File, opts.file) {
+		return false
+	}
+
+	// filter on the enclosing function and the receiver
+	if opts.within != "" && !withinMatches(loc.Within, opts.within) {
+		return false
+	}
+	if opts.receiver != "" && !receiverMatches(ref, opts.receiver) {
+		return false
+	}
+
+	return matchesFilters(ref, opts.filters)
+}
+
+// returns true if the reference is of the type given by the filter
+func isType(ref Reference, wtype string) bool {
+	switch r := ref.(type) {
+	case *Function:
+		return wtype == ResultsFunctions
+	case *Variable:
+		return wtype == ResultsVariables || (wtype == ResultsGlobals && r.IsGlobal())
+	case *Constant:
+		return wtype == ResultsConstants
+	case *Struct:
+		return wtype == ResultsStructs
+	case *Interface:
+		return wtype == ResultsInterfaces
+	case *Field:
+		return wtype == ResultsFields
+	case *StringLiteral:
+		return wtype == ResultsStrings
+	case *SourceLine:
+		return wtype == ResultsLines
+	case *NamedType:
+		switch r.Kind {
+		case TypeKindAlias:
+			return wtype == ResultsAliases
+		case TypeKindBasic:
+			return wtype == ResultsBasicTypes
+		case TypeKindFunc:
+			return wtype == ResultsFuncTypes
+		case TypeKindMap:
+			return wtype == ResultsMapTypes
+		case TypeKindSlice, TypeKindArray:
+			return wtype == ResultsSliceTypes
+		case TypeKindChan:
+			return wtype == ResultsChanTypes
+		case TypeKindPointer:
+			return wtype == ResultsPointerTypes
+		case TypeKindNamed:
+			return wtype == ResultsNamedTypes
+		}
+	}
+	return false
 }
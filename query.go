@@ -0,0 +1,402 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// kindNames maps the values of the kind filter of a query to the type filters
+// they stand for
+var kindNames = map[string]string{
+	"func":       ResultsFunctions,
+	"function":   ResultsFunctions,
+	"functions":  ResultsFunctions,
+	"struct":     ResultsStructs,
+	"structs":    ResultsStructs,
+	"interface":  ResultsInterfaces,
+	"interfaces": ResultsInterfaces,
+	"var":        ResultsVariables,
+	"variable":   ResultsVariables,
+	"variables":  ResultsVariables,
+	"global":     ResultsGlobals,
+	"globals":    ResultsGlobals,
+	"const":      ResultsConstants,
+	"constant":   ResultsConstants,
+	"constants":  ResultsConstants,
+	"field":      ResultsFields,
+	"fields":     ResultsFields,
+	"alias":      ResultsAliases,
+	"aliases":    ResultsAliases,
+	"basic":      ResultsBasicTypes,
+	"functype":   ResultsFuncTypes,
+	"map":        ResultsMapTypes,
+	"slice":      ResultsSliceTypes,
+	"chan":       ResultsChanTypes,
+	"pointer":    ResultsPointerTypes,
+	"named":      ResultsNamedTypes,
+	"string":     ResultsStrings,
+	"strings":    ResultsStrings,
+	"line":       ResultsLines,
+	"lines":      ResultsLines,
+}
+
+// queryFields maps the fields a query can filter on, and their aliases, to the
+// field of the queryFilter
+var queryFields = map[string]string{
+	"kind":     "kind",
+	"recv":     "recv",
+	"receiver": "recv",
+	"file":     "file",
+	"in":       "in",
+	"within":   "in",
+	"decl":     "decl",
+	"name":     "name",
+	"pkg":      "pkg",
+	"package":  "pkg",
+}
+
+// queryFilter is a field filter of a structured query, eg. kind:func
+type queryFilter struct {
+	field  string
+	value  string
+	negate bool
+}
+
+// ParseQuery parses a structured query, setting the filters it holds on opts,
+// and returns the text to search for. A query is made of words, each either
+// text to search for or a filter on a field:
+//
+//	kind:func recv:Server file:handlers.go in:Listen decl:yes name:pre*
+//
+// A word starting with - excludes the results it matches instead, and double
+// quotes keep text with spaces or a leading - together. Filters on different
+// fields must all match, while a result only has to match one of the filters
+// on the same field.
+//
+// How the text is read depends on the search mode of opts. Identifiers have
+// no spaces, so searching for them fails on more than one word of text. Doc
+// comment searches look for the words of the text, and a quoted phrase must be
+// in the doc comment as it is. Regular expression and string searches take the
+// text as it is, quotes and all, see parseRawQuery.
+func ParseQuery(input string, opts *QueryOptions) (string, error) {
+	switch opts.mode {
+	case ModeRegex, ModeStrings:
+		return parseRawQuery(input, opts)
+	}
+	docs := opts.mode == ModeDocs
+
+	var text []string
+	for _, token := range tokenizeQuery(input) {
+		word := token.text
+		if word == "" {
+			continue
+		}
+		negate := false
+		if !token.quoted && len(word) > 1 && word[0] == '-' {
+			negate = true
+			word = word[1:]
+		}
+
+		if i := strings.Index(word, ":"); i > 0 && !token.quoted {
+			// a field without a value is just text in the doc comments
+			field, ok := queryFields[strings.ToLower(word[:i])]
+			if ok && (!docs || i+1 < len(word)) {
+				f, err := newQueryFilter(field, word[i+1:], negate)
+				if err != nil {
+					return "", err
+				}
+				opts.filters = append(opts.filters, f)
+				continue
+			}
+		}
+
+		if docs {
+			// phrases must be in the doc comment, and words left out of it
+			// exclude the doc comments containing them
+			if phrase := docPhrase(word); negate || strings.Contains(phrase, " ") {
+				opts.filters = append(opts.filters, queryFilter{"doc", phrase, negate})
+			}
+		} else if negate {
+			// leave out the words containing it
+			opts.filters = append(opts.filters, queryFilter{"name", "*" + word + "*", true})
+			continue
+		}
+		if !negate {
+			text = append(text, word)
+		}
+	}
+
+	if !docs && (len(text) > 1 || len(text) == 1 && strings.IndexFunc(text[0], unicode.IsSpace) >= 0) {
+		return "", fmt.Errorf("%q is more than one word, search the docs or strings for text", strings.Join(text, " "))
+	}
+
+	// without text, search the words the name filter starts with
+	if len(text) == 0 {
+		for _, f := range opts.filters {
+			if f.field == "name" && !f.negate {
+				return globPrefix(f.value), nil
+			}
+		}
+	}
+	return strings.Join(text, " "), nil
+}
+
+// parseRawQuery takes the filters out of a query whose text is a regular
+// expression or a message, and returns the rest of it as it is. Only words
+// naming a field and giving it a value are filters, so "file: %s" in a message
+// is still text, and the value may be quoted, eg. in:"Server.Listen".
+func parseRawQuery(input string, opts *QueryOptions) (string, error) {
+	var text []string
+	filtered := false // the last word was a filter
+	rest := input
+	for rest != "" {
+		i := strings.IndexFunc(rest, unicode.IsSpace)
+		if i < 0 {
+			i = len(rest)
+		}
+		word := rest[:i]
+		// the whitespace after the word, kept along with the text
+		j := i + len(rest[i:]) - len(strings.TrimLeftFunc(rest[i:], unicode.IsSpace))
+		space := rest[i:j]
+		rest = rest[j:]
+
+		negate := strings.HasPrefix(word, "-")
+		fieldValue := strings.TrimPrefix(word, "-")
+		if k := strings.Index(fieldValue, ":"); k > 0 && k+1 < len(fieldValue) {
+			if field, ok := queryFields[strings.ToLower(fieldValue[:k])]; ok {
+				value := fieldValue[k+1:]
+				if len(value) > 1 && value[0] == '"' && value[len(value)-1] == '"' {
+					value = value[1 : len(value)-1]
+				}
+				f, err := newQueryFilter(field, value, negate)
+				if err != nil {
+					return "", err
+				}
+				opts.filters = append(opts.filters, f)
+				filtered = true
+				continue
+			}
+		}
+		text = append(text, word+space)
+		filtered = false
+	}
+	raw := strings.Join(text, "")
+	if filtered {
+		// the whitespace before a filter at the end isn't part of the text
+		raw = strings.TrimRightFunc(raw, unicode.IsSpace)
+	}
+	return raw, nil
+}
+
+func newQueryFilter(field, value string, negate bool) (queryFilter, error) {
+	if value == "" {
+		return queryFilter{}, fmt.Errorf("missing value for %s", field)
+	}
+
+	switch field {
+	case "kind":
+		kind, ok := kindNames[strings.ToLower(value)]
+		if !ok {
+			return queryFilter{}, fmt.Errorf("unknown kind %s", value)
+		}
+		value = kind
+	case "decl":
+		switch strings.ToLower(value) {
+		case "yes", "true":
+			value = "yes"
+		case "no", "false":
+			value = "no"
+		default:
+			return queryFilter{}, fmt.Errorf("decl must be yes or no, not %s", value)
+		}
+	case "name":
+		if _, err := path.Match(value, ""); err != nil {
+			return queryFilter{}, fmt.Errorf("bad name pattern %s", value)
+		}
+	case "recv":
+		value = strings.TrimPrefix(value, "*")
+	case "file":
+		if _, err := path.Match(value, ""); err != nil {
+			return queryFilter{}, fmt.Errorf("bad file pattern %s", value)
+		}
+	}
+	return queryFilter{field, value, negate}, nil
+}
+
+// matches returns true if the Reference has the value of the filter in its
+// field, regardless of whether the filter is negated
+func (f queryFilter) matches(ref Reference) bool {
+	loc := ref.GetLocation()
+	switch f.field {
+	case "kind":
+		return isType(ref, f.value)
+	case "recv":
+		return receiverMatches(ref, f.value)
+	case "file":
+		return fileMatches(loc.File, f.value)
+	case "in":
+		return withinMatches(loc.Within, f.value)
+	case "decl":
+		return isDeclaration(ref) == (f.value == "yes")
+	case "name":
+		ok, _ := path.Match(f.value, referenceName(ref))
+		return ok
+	case "pkg":
+		return loc.Package == f.value || loc.ImportPath == f.value
+	case "doc":
+		return strings.Contains(docPhrase(docOf(ref)), f.value)
+	}
+	return false
+}
+
+// docPhrase returns the text in lower case with its words separated by single
+// spaces, so a phrase matches a doc comment regardless of its line breaks
+func docPhrase(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+// matchesFilters returns true if the Reference matches at least one filter on
+// every field that has any, and none of the negated ones
+func matchesFilters(ref Reference, filters []queryFilter) bool {
+	wanted := make(map[string]bool)
+	for _, f := range filters {
+		if f.negate {
+			if f.matches(ref) {
+				return false
+			}
+			continue
+		}
+		if !wanted[f.field] {
+			wanted[f.field] = f.matches(ref)
+		}
+	}
+	for _, f := range filters {
+		if !f.negate && !wanted[f.field] {
+			return false
+		}
+	}
+	return true
+}
+
+// receiverMatches returns true if the Reference is a method on the receiver,
+// or a field of the struct, eg. Index or *Index
+func receiverMatches(ref Reference, recv string) bool {
+	return receiverOf(ref) == strings.TrimPrefix(recv, "*")
+}
+
+// withinMatches returns true if the Location.Within is the function, eg. Query
+// or Querier.Query, or one of the function literals in it
+func withinMatches(within, fn string) bool {
+	name := withinName(within)
+	if name == "" {
+		return false
+	}
+	for {
+		if name == fn || strings.HasSuffix(name, "."+fn) {
+			return true
+		}
+		// main.func1.2 is within main.func1, which is within main
+		i := strings.LastIndex(name, ".func")
+		if i < 0 {
+			return false
+		}
+		name = name[:i]
+	}
+}
+
+// fileMatches returns true if the file is the one given by the pattern, which
+// is either its path or name, the path of a directory it is in, or a glob
+// pattern of its path or name, eg. handlers.go, util/, util or *_test.go
+func fileMatches(file, pattern string) bool {
+	file = filepath.ToSlash(file)
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+	if file == pattern || path.Base(file) == pattern {
+		return true
+	}
+	if dir := strings.TrimSuffix(pattern, "/"); dir != "" && strings.HasPrefix(file, dir+"/") {
+		return true
+	}
+	if ok, _ := path.Match(pattern, file); ok {
+		return true
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(file))
+		return ok
+	}
+	return false
+}
+
+// receiverOf returns the type of the receiver of a method, or the struct
+// owning a field. The Reciever of a call is whatever the method is called on,
+// and that of a function literal is the method it is in, so calls are only
+// matched with type information and function literals never are.
+func receiverOf(ref Reference) string {
+	switch r := ref.(type) {
+	case *Function:
+		if r.IsDecl && !r.Literal {
+			return r.Reciever
+		}
+		return r.recvType
+	case *Field:
+		return r.Struct
+	}
+	return ""
+}
+
+// withinName returns the name of the function in a Location.Within, eg.
+// "Server.Listen" for "Server.Listen (main.go:16)"
+func withinName(within string) string {
+	if i := strings.Index(within, " ("); i >= 0 {
+		return within[:i]
+	}
+	return within
+}
+
+// globPrefix returns the text a glob pattern starts with before any of its
+// special characters
+func globPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+type queryToken struct {
+	text   string
+	quoted bool // started with a double quote
+}
+
+// tokenizeQuery splits a query into words at whitespace outside of double
+// quotes. The quotes themselves are dropped.
+func tokenizeQuery(input string) []queryToken {
+	var tokens []queryToken
+	var cur []rune
+	quoted, inQuotes, started := false, false, false
+	flush := func() {
+		if started {
+			tokens = append(tokens, queryToken{string(cur), quoted})
+		}
+		cur, quoted, started = nil, false, false
+	}
+
+	for _, r := range input {
+		switch {
+		case r == '"':
+			if !started {
+				quoted = true
+			}
+			inQuotes = !inQuotes
+			started = true
+		case unicode.IsSpace(r) && !inQuotes:
+			flush()
+		default:
+			cur = append(cur, r)
+			started = true
+		}
+	}
+	flush()
+	return tokens
+}
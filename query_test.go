@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuery(t *testing.T) {
+	assert := assert.New(t)
+
+	opts := DefaultQueryOptions()
+	text, err := ParseQuery(`kind:func recv:*Server file:handlers.go in:Listen decl:yes name:pre*`, opts)
+	assert.NoError(err)
+	assert.Equal("pre", text)
+	assert.Equal([]queryFilter{
+		{"kind", ResultsFunctions, false},
+		{"recv", "Server", false},
+		{"file", "handlers.go", false},
+		{"in", "Listen", false},
+		{"decl", "yes", false},
+		{"name", "pre*", false},
+	}, opts.filters)
+
+	opts = DefaultQueryOptions()
+	text, err = ParseQuery(`-kind:const -test "-x" in:"Server.Listen"`, opts)
+	assert.NoError(err)
+	assert.Equal("-x", text)
+	assert.Equal([]queryFilter{
+		{"kind", ResultsConstants, true},
+		{"name", "*test*", true},
+		{"in", "Server.Listen", false},
+	}, opts.filters)
+
+	// no identifier has more than one word
+	_, err = ParseQuery(`"starts the server"`, DefaultQueryOptions())
+	assert.Error(err)
+	_, err = ParseQuery(`Listen Serve kind:func`, DefaultQueryOptions())
+	assert.Error(err)
+
+	// doc comments must have the phrases, and not the words left out
+	opts = DefaultQueryOptions()
+	opts.mode = ModeDocs
+	text, err = ParseQuery(`"Starts  the server" -test kind:func file:`, opts)
+	assert.NoError(err)
+	assert.Equal("Starts  the server file:", text)
+	assert.Equal([]queryFilter{
+		{"doc", "starts the server", false},
+		{"doc", "test", true},
+		{"kind", ResultsFunctions, false},
+	}, opts.filters)
+
+	// messages and regular expressions are kept as they are, and unknown
+	// fields or fields without a value are just text
+	opts = DefaultQueryOptions()
+	opts.mode = ModeStrings
+	text, err = ParseQuery(`open x: "permission"  denied -dry file: kind:string -file:*_test.go`, opts)
+	assert.NoError(err)
+	assert.Equal(`open x: "permission"  denied -dry file:`, text)
+	assert.Equal([]queryFilter{
+		{"kind", ResultsStrings, false},
+		{"file", "*_test.go", true},
+	}, opts.filters)
+
+	opts = DefaultQueryOptions()
+	opts.mode = ModeRegex
+	text, err = ParseQuery(`in:"Server.Listen" ^func\s+(Get|Set) `, opts)
+	assert.NoError(err)
+	assert.Equal(`^func\s+(Get|Set) `, text)
+	assert.Equal([]queryFilter{{"in", "Server.Listen", false}}, opts.filters)
+
+	_, err = ParseQuery(`kind:nope`, DefaultQueryOptions())
+	assert.Error(err)
+	_, err = ParseQuery(`decl:maybe`, DefaultQueryOptions())
+	assert.Error(err)
+	_, err = ParseQuery(`file:`, DefaultQueryOptions())
+	assert.Error(err)
+	opts = DefaultQueryOptions()
+	opts.mode = ModeStrings
+	_, err = ParseQuery(`missing kind:nope`, opts)
+	assert.Error(err)
+}
+
+func TestMatchesFilters(t *testing.T) {
+	assert := assert.New(t)
+	listen := &Function{
+		Location: &Location{File: "main.go", Within: "Server.Listen (main.go:16)"},
+		Name:     "Listen",
+		Reciever: "Server",
+		IsDecl:   true,
+	}
+	port := &Variable{
+		Location: &Location{File: "util/util.go", Within: "Server.Listen (main.go:16)"},
+		Name:     "port",
+	}
+
+	filters := func(query string) []queryFilter {
+		opts := DefaultQueryOptions()
+		_, err := ParseQuery(query, opts)
+		assert.NoError(err)
+		return opts.filters
+	}
+
+	assert.True(matchesFilters(listen, filters("kind:func recv:Server decl:yes")))
+	assert.False(matchesFilters(port, filters("kind:func recv:Server decl:yes")))
+	// either kind will do
+	assert.True(matchesFilters(port, filters("kind:func kind:var")))
+	assert.True(matchesFilters(port, filters("in:Listen file:util.go")))
+	assert.True(matchesFilters(port, filters("in:Server.Listen")))
+	assert.False(matchesFilters(port, filters("in:Listen -file:util.go")))
+	assert.False(matchesFilters(listen, filters("-List")))
+	assert.True(matchesFilters(listen, filters("name:L*n")))
+}
+
+func TestFileMatches(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(fileMatches("util/util.go", "util/util.go"))
+	assert.True(fileMatches("util/util.go", "./util/util.go"))
+	assert.True(fileMatches("util/util.go", "util.go"))
+	// directories
+	assert.True(fileMatches("util/util.go", "util"))
+	assert.True(fileMatches("util/util.go", "util/"))
+	assert.True(fileMatches("a/b/c.go", "a"))
+	assert.False(fileMatches("utils/util.go", "util"))
+	// globs, of the path or the name
+	assert.True(fileMatches("util/util_test.go", "*_test.go"))
+	assert.True(fileMatches("util/util_test.go", "util/*.go"))
+	assert.False(fileMatches("a/util/util.go", "util/*.go"))
+	assert.False(fileMatches("util/util.go", "*_test.go"))
+}
+
+func TestWithinMatches(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(withinMatches("Querier.Query (querier.go:10)", "Querier.Query"))
+	assert.True(withinMatches("Querier.Query (querier.go:10)", "Query"))
+	assert.False(withinMatches("Querier.Query (querier.go:10)", "Querier"))
+	assert.False(withinMatches("Querier.Query (querier.go:10)", "ery"))
+	// function literals are within the function they are in
+	assert.True(withinMatches("Querier.Query.func1.2 (querier.go:12)", "Querier.Query"))
+	assert.True(withinMatches("main.func1 (main.go:30)", "main"))
+	assert.False(withinMatches("", "main"))
+}
+
+const receiverSource = `package main
+
+type Server struct {
+	port int
+}
+
+func (s *Server) Listen() {
+	go func() {
+		s.port++
+	}()
+}
+
+func main() {
+	srv := &Server{}
+	srv.Listen()
+}
+`
+
+func TestReceiverMatches(t *testing.T) {
+	assert := assert.New(t)
+	root := writeProject(t, map[string]string{
+		"go.mod":  "module example.com/proj\n",
+		"main.go": receiverSource,
+	})
+	defer os.RemoveAll(root)
+
+	// the words on Server, declared or called
+	onServer := func(idx *Index) []string {
+		var words []string
+		for _, refs := range idx.references {
+			for _, ref := range refs {
+				if receiverMatches(ref, "*Server") {
+					words = append(words, fmt.Sprintf("%s:%d", referenceName(ref), ref.GetLocation().Line))
+				}
+			}
+		}
+		sort.Strings(words)
+		return words
+	}
+
+	fm := NewFileManager(root)
+	// the call on srv is on a variable, and the literal in Listen isn't a
+	// method
+	assert.Equal([]string{"Listen:7", "port:4", "port:9"}, onServer(BuildIndex(fm)))
+	assert.Equal([]string{"Listen:15", "Listen:7", "port:4", "port:9"}, onServer(BuildTypedIndex(fm)))
+}
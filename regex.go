@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+)
+
+// regexMatches returns the references to every word matching the regular
+// expression
+func (s *Snapshot) regexMatches(re *regexp.Regexp) []Reference {
+	var results []Reference
+	for word, refs := range s.idx.references {
+		if re.MatchString(word) {
+			results = append(results, refs...)
+		}
+	}
+	sort.Sort(sort.Reverse(SmartSort(results)))
+	return results
+}
+
+// sourceMatches returns a SourceLine for every line of source matching the
+// regular expression, by file and line. Only the files the TrigramIndex finds
+// may match are read.
+func (s *Snapshot) sourceMatches(re *regexp.Regexp) []Reference {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return nil
+	}
+
+	var results []Reference
+	for _, file := range s.trigrams.Candidates(parsed) {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			fmt.Printf("could not search %s: %v\n", file, err)
+			continue
+		}
+		results = append(results, s.idx.matchLines(s.idx.fileMgr.Rel(file), data, re)...)
+	}
+	return results
+}
+
+// matchLines returns a SourceLine for every line of the file matching the
+// regular expression, located at the first match on the line
+func (x *Index) matchLines(relPath string, data []byte, re *regexp.Regexp) []Reference {
+	var fns []*Function
+	for _, decls := range x.functions {
+		for _, fn := range decls {
+			if fn.File == relPath {
+				fns = append(fns, fn)
+			}
+		}
+	}
+
+	var results []Reference
+	offset := 0
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if m := re.FindIndex(line); m != nil {
+			loc := &Location{
+				File:      relPath,
+				Line:      i + 1,
+				Column:    m[0] + 1,
+				Offset:    offset + m[0],
+				EndLine:   i + 1,
+				EndColumn: m[1] + 1,
+				EndOffset: offset + m[1],
+			}
+			if pkg, ok := x.filePackages[relPath]; ok {
+				loc.Package = pkg.Name
+				loc.ImportPath = pkg.ImportPath
+			}
+			// the innermost function is the last to start before the match
+			var within *Function
+			for _, fn := range fns {
+				if fn.Wraps(loc) && (within == nil || fn.Offset > within.Offset) {
+					within = fn
+				}
+			}
+			if within != nil {
+				loc.Within = within.Info()
+			}
+			results = append(results, &SourceLine{
+				Location: loc,
+				Text:     string(bytes.TrimSpace(line)),
+			})
+		}
+		offset += len(line) + 1
+	}
+	return results
+}
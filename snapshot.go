@@ -0,0 +1,78 @@
+package main
+
+// Snapshot is an Index along with the Trie, FoldedTrie, SuffixArray and
+// Abbreviations of its words, and the TrigramIndex of its source. A Snapshot
+// is never modified once the Querier publishes it, so requests can keep
+// reading one while the next is being built.
+type Snapshot struct {
+	idx      *Index
+	trie     *Trie
+	folded   *FoldedTrie
+	suffixes *SuffixArray
+	abbrevs  *Abbreviations
+	trigrams *TrigramIndex
+}
+
+// clone returns a copy of the Index that can be updated without affecting the
+// original, with the given FileManager. An update only drops and re-adds the
+// references of the changed files, and the passes after it only modify the
+// references they add, so the references and the slices holding them are
+// shared. The slices are never modified in place, and are capped so appending
+// to them in the clone makes a copy. Packages are copied since their imports
+// and importers are linked again after every update.
+func (x *Index) clone(fm *FileManager) *Index {
+	c := newIndex(fm)
+	c.fset = x.fset
+	c.typed = x.typed
+
+	for word, refs := range x.references {
+		c.references[word] = refs[:len(refs):len(refs)]
+	}
+	for file, spans := range x.idents {
+		c.idents[file] = spans[:len(spans):len(spans)]
+	}
+	for file, literals := range x.literals {
+		c.literals[file] = literals[:len(literals):len(literals)]
+	}
+	for name, fns := range x.functions {
+		c.functions[name] = fns[:len(fns):len(fns)]
+	}
+	for name, structs := range x.structs {
+		c.structs[name] = structs[:len(structs):len(structs)]
+	}
+	for name, ifaces := range x.interfaces {
+		c.interfaces[name] = ifaces[:len(ifaces):len(ifaces)]
+	}
+	for name, named := range x.types {
+		c.types[name] = named[:len(named):len(named)]
+	}
+	for word, refs := range x.docs {
+		c.docs[word] = refs[:len(refs):len(refs)]
+	}
+	for ref, id := range x.objectSymbols {
+		c.objectSymbols[ref] = id
+	}
+	for word, ids := range x.wordSymbols {
+		c.wordSymbols[word] = ids
+	}
+	for id, refs := range x.symbols {
+		c.symbols[id] = refs[:len(refs):len(refs)]
+	}
+
+	pkgs := make(map[*Package]*Package, len(x.packages))
+	for path, pkg := range x.packages {
+		p := *pkg
+		p.Files = append([]string(nil), pkg.Files...)
+		p.Imports = append([]string(nil), pkg.Imports...)
+		p.Importers = append([]string(nil), pkg.Importers...)
+		pkgs[pkg] = &p
+		c.packages[path] = &p
+	}
+	for file, pkg := range x.filePackages {
+		c.filePackages[file] = pkgs[pkg]
+	}
+	for file, imports := range x.fileImports {
+		c.fileImports[file] = imports
+	}
+	return c
+}
@@ -0,0 +1,143 @@
+/**
+ * Initialize import graph object and data loading.
+ * @param {Object} d3Object Object for d3, injection used for testing.
+ */
+var importGraph = function(d3Object) {
+  this.d3 = d3Object;
+};
+
+/**
+ * Load graph data and draw chart.
+ */
+importGraph.prototype.drawChart = function() {
+  var self = this;
+  d3.json('imports.json', function(error, data) {
+    if (error || data['error']) {
+      console.log(error || data);
+      return;
+    }
+    self.graphData = data;
+    self.listCycles(data.cycles);
+    self.graph(self.getGraphConfig());
+  });
+};
+
+/**
+ * Get graph dimension configuration.
+ * @return {Object} graphConfig Object containing graph dimension size.
+ */
+importGraph.prototype.getGraphConfig = function() {
+  var graphConfig = {};
+  graphConfig.chartWidth = 960;
+  graphConfig.chartHeight = 700;
+  graphConfig.linkDistance = 120;
+  graphConfig.charge = -400;
+  graphConfig.nodeRadius = 8;
+  graphConfig.elemID = '#import-graph';
+  return graphConfig;
+};
+
+/**
+ * List the import cycles found between packages. The packages of a cycle are
+ * sorted by import path rather than by the order they import each other in,
+ * so they are shown as a set.
+ * @param {Array} cycles List of cycles, each a list of import paths.
+ */
+importGraph.prototype.listCycles = function(cycles) {
+  if (!cycles || cycles.length == 0) {
+    $('#import-cycles').html('<p class="text-muted">No import cycles</p>');
+    return;
+  }
+  var list = $('<ul></ul>');
+  cycles.forEach(function(cycle) {
+    var item = $('<li></li>').append('{ ');
+    cycle.forEach(function(path, i) {
+      if (i > 0) {
+        item.append(', ');
+      }
+      item.append($('<code></code>').text(path));
+    });
+    list.append(item.append(' }'));
+  });
+  $('#import-cycles').empty()
+      .append('<h6>Import cycles</h6>')
+      .append('<p class="text-muted">Packages that import one another</p>')
+      .append(list);
+};
+
+/**
+ * Graph packages as nodes and imports as directed links.
+ * @param {Object} config Object for chart dimensions.
+ */
+importGraph.prototype.graph = function(config) {
+  var d3 = this.d3;
+  var nodes = this.graphData.nodes;
+  var links = this.graphData.edges;
+  var nodeColor = '#23C9B3';
+  var cycleColor = '#DC3545';
+
+  var svg = d3.select(config.elemID)
+      .append('svg')
+      .attr('width', config.chartWidth)
+      .attr('height', config.chartHeight);
+
+  // Arrow heads for the import direction.
+  svg.append('defs').selectAll('marker')
+      .data(['import', 'cycle'])
+      .enter().append('marker')
+      .attr('id', function(d) {return 'arrow-' + d; })
+      .attr('viewBox', '0 -5 10 10')
+      .attr('refX', 20)
+      .attr('markerWidth', 6)
+      .attr('markerHeight', 6)
+      .attr('orient', 'auto')
+      .append('path')
+      .attr('d', 'M0,-5L10,0L0,5')
+      .style('fill', function(d) {
+        return (d == 'cycle') ? cycleColor : '#999'; });
+
+  var force = d3.layout.force()
+      .nodes(nodes)
+      .links(links)
+      .size([config.chartWidth, config.chartHeight])
+      .linkDistance(config.linkDistance)
+      .charge(config.charge)
+      .on('tick', tick)
+      .start();
+
+  var link = svg.selectAll('line.importLink')
+      .data(links)
+      .enter().append('line')
+      .attr('class', function(d) {
+        return d.in_cycle ? 'importLink cycleLink' : 'importLink'; })
+      .attr('marker-end', function(d) {
+        return 'url(#arrow-' + (d.in_cycle ? 'cycle' : 'import') + ')'; });
+
+  var node = svg.selectAll('g.importNode')
+      .data(nodes)
+      .enter().append('g')
+      .attr('class', 'importNode')
+      .call(force.drag);
+  node.append('circle')
+      .attr('r', config.nodeRadius)
+      .style('fill', function(d) {
+        return d.in_cycle ? cycleColor : nodeColor; });
+  node.append('text')
+      .attr('x', 12)
+      .attr('dy', '.35em')
+      .text(function(d) {return d.import_path; });
+  node.append('title')
+      .text(function(d) {return d.import_path + ' (' + d.file_count + ' files)'; });
+
+  /**
+   * Move nodes and links to their new positions.
+   */
+  function tick() {
+    link.attr('x1', function(d) {return d.source.x; })
+        .attr('y1', function(d) {return d.source.y; })
+        .attr('x2', function(d) {return d.target.x; })
+        .attr('y2', function(d) {return d.target.y; });
+    node.attr('transform', function(d) {
+      return 'translate(' + d.x + ',' + d.y + ')'; });
+  }
+};
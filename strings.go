@@ -0,0 +1,116 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// minMessageLetters is how many letters and digits a string literal needs
+// outside of its format verbs to be matched against a message. Shorter ones,
+// like ", " or "%s: %v", would match just about anything.
+const minMessageLetters = 3
+
+// SearchStrings returns the string literals matching a message, most specific
+// first. A literal matches if it contains the message, so part of a message
+// is enough, or if the message could have been formatted from it, eg. the
+// message "open tree.json: permission denied" and the literal "open %s: %v".
+// Matching ignores case and the whitespace around the message.
+func (x *Index) SearchStrings(message string) []Reference {
+	message = strings.ToLower(strings.TrimSpace(message))
+	if message == "" {
+		return nil
+	}
+
+	scores := make(map[Reference]int)
+	for _, literals := range x.literals {
+		for _, l := range literals {
+			value := strings.ToLower(l.Value)
+			if strings.Contains(value, message) {
+				scores[l] = len(message)
+				continue
+			}
+			segments := formatSegments(value)
+			if countLetters(segments) >= minMessageLetters && matchesSegments(segments, message) {
+				scores[l] = len(strings.Join(segments, ""))
+			}
+		}
+	}
+
+	results := make([]Reference, 0, len(scores))
+	for ref := range scores {
+		results = append(results, ref)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if scores[results[i]] != scores[results[j]] {
+			return scores[results[i]] > scores[results[j]]
+		}
+		li, lj := results[i].GetLocation(), results[j].GetLocation()
+		if li.File != lj.File {
+			return li.File < lj.File
+		}
+		return li.Offset < lj.Offset
+	})
+	return results
+}
+
+// formatSegments splits a format string, as used by fmt.Printf and friends,
+// into the text between its verbs. The text is trimmed of whitespace, so a
+// trailing newline doesn't have to be part of the message.
+func formatSegments(format string) []string {
+	var segments []string
+	var seg []byte
+	add := func() {
+		if s := strings.TrimSpace(string(seg)); s != "" {
+			segments = append(segments, s)
+		}
+		seg = nil
+	}
+
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' {
+			seg = append(seg, c)
+			continue
+		}
+		if i+1 < len(format) && format[i+1] == '%' {
+			seg = append(seg, '%')
+			i++
+			continue
+		}
+		// skip the flags, width, precision and argument index, the loop
+		// skips the verb itself
+		i++
+		for i < len(format) && strings.IndexByte("+-# 0123456789.*[]", format[i]) >= 0 {
+			i++
+		}
+		add()
+	}
+	add()
+	return segments
+}
+
+// matchesSegments returns true if the segments appear in the message in order
+func matchesSegments(segments []string, message string) bool {
+	pos := 0
+	for _, seg := range segments {
+		i := strings.Index(message[pos:], seg)
+		if i < 0 {
+			return false
+		}
+		pos += i + len(seg)
+	}
+	return true
+}
+
+func countLetters(segments []string) int {
+	n := 0
+	for _, seg := range segments {
+		for _, r := range seg {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				n++
+			}
+		}
+	}
+	return n
+}
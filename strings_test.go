@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatSegments(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"open", ":"}, formatSegments("open %s: %v\n"))
+	assert.Equal([]string{"100% done in", "s"}, formatSegments("100%% done in %.2fs"))
+	assert.Equal([]string{"[", "] of", "items"}, formatSegments("[%-10s] of %[2]*d items"))
+	assert.Empty(formatSegments("%s%d"))
+	assert.Equal([]string{"no verbs"}, formatSegments("  no verbs\t"))
+}
+
+const stringsSource = `package main
+
+import "fmt"
+
+type Config struct {
+	Path string ` + "`json:\"path\"`" + `
+}
+
+func main() {
+	fmt.Printf("Error creating callstack json: %v\n", nil)
+	fmt.Printf("%s: %v\n", "a", "b")
+	fmt.Println("callstack json")
+	fmt.Printf("open %s: permission denied\n", "x")
+}
+`
+
+func TestSearchStrings(t *testing.T) {
+	assert := assert.New(t)
+	root := writeProject(t, map[string]string{"main.go": stringsSource})
+	defer os.RemoveAll(root)
+	idx := BuildIndex(NewFileManager(root))
+
+	search := func(message string) []string {
+		var found []string
+		for _, ref := range idx.SearchStrings(message) {
+			found = append(found, ref.(*StringLiteral).Value)
+		}
+		return found
+	}
+
+	// the literal the message was formatted from ranks before the one that
+	// only shares part of it, and "%s: %v" is too short to match anything
+	assert.Equal([]string{
+		"Error creating callstack json: %v\n",
+		"callstack json",
+	}, search("  ERROR creating callstack json: EOF\n"))
+	assert.Equal([]string{"open %s: permission denied\n"}, search("open tree.json: permission denied"))
+	// part of a message is enough
+	assert.Equal([]string{"Error creating callstack json: %v\n"}, search("creating callstack"))
+	// import paths and struct tags aren't indexed
+	assert.Empty(search("fmt"))
+	assert.Empty(search(`json:"path"`))
+	assert.Empty(search(" "))
+
+	if refs := idx.SearchStrings("permission denied"); assert.Len(refs, 1) {
+		loc := refs[0].GetLocation()
+		assert.Equal(13, loc.Line)
+		assert.Equal("main (main.go:9)", loc.Within)
+	}
+}
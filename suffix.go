@@ -0,0 +1,75 @@
+package main
+
+import (
+	"index/suffixarray"
+	"sort"
+	"strings"
+)
+
+// wordSeparator joins the words indexed by a SuffixArray. It can't be part of
+// an identifier, so no match spans two words.
+const wordSeparator = "\x00"
+
+// SuffixArray indexes words for substring search, which the Trie can't do
+// since it only matches from the start of a word. It is built once and
+// rebuilt, rather than patched, when the words change.
+type SuffixArray struct {
+	index  *suffixarray.Index
+	words  []string
+	starts []int // offset of each word in the indexed data
+}
+
+// NewSuffixArray builds a SuffixArray of the given words
+func NewSuffixArray(words []string) *SuffixArray {
+	words = append([]string(nil), words...)
+	sort.Strings(words)
+
+	starts := make([]int, len(words))
+	offset := 0
+	for i, w := range words {
+		starts[i] = offset
+		offset += len(w) + len(wordSeparator)
+	}
+	data := []byte(strings.Join(words, wordSeparator))
+
+	return &SuffixArray{
+		index:  suffixarray.New(data),
+		words:  words,
+		starts: starts,
+	}
+}
+
+// SuffixArrayFromIndex builds a SuffixArray of the words of the given index
+func SuffixArrayFromIndex(idx *Index) *SuffixArray {
+	words := make([]string, 0, len(idx.references))
+	for word := range idx.references {
+		words = append(words, word)
+	}
+	return NewSuffixArray(words)
+}
+
+// Lookup returns the words containing s, in sorted order
+func (a *SuffixArray) Lookup(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	offsets := a.index.Lookup([]byte(s), -1)
+	found := make(map[int]bool, len(offsets))
+	for _, offset := range offsets {
+		// the word is the last one starting at or before the offset
+		found[sort.SearchInts(a.starts, offset+1)-1] = true
+	}
+
+	matches := make([]int, 0, len(found))
+	for i := range found {
+		matches = append(matches, i)
+	}
+	sort.Ints(matches)
+
+	words := make([]string, len(matches))
+	for i, m := range matches {
+		words[i] = a.words[m]
+	}
+	return words
+}
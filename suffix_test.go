@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuffixArrayLookup(t *testing.T) {
+	assert := assert.New(t)
+	sa := NewSuffixArray([]string{"GetFilePreview", "preview", "Preview", "BuildIndex", "Index"})
+
+	assert.Equal([]string{"GetFilePreview", "Preview"}, sa.Lookup("Preview"))
+	assert.Equal([]string{"BuildIndex", "Index"}, sa.Lookup("Index"))
+	assert.Equal([]string{"GetFilePreview"}, sa.Lookup("eP"))
+	// matches never span two words
+	assert.Empty(sa.Lookup("IndexIndex"))
+	assert.Empty(sa.Lookup("wB"))
+	assert.Empty(sa.Lookup(""))
+}
+
+// benchmarkWords returns n distinct identifiers made up of common segments
+func benchmarkWords(n int) []string {
+	segments := []string{
+		"Build", "Call", "Stack", "File", "Preview", "Index", "Query", "Trie",
+		"Get", "Set", "Parse", "Read", "Write", "Node", "Package", "Import",
+	}
+	words := make([]string, n)
+	for i := range words {
+		a := segments[i%len(segments)]
+		b := segments[(i/len(segments))%len(segments)]
+		words[i] = fmt.Sprintf("%s%s%d", a, b, i)
+	}
+	return words
+}
+
+var benchmarkSizes = []int{1000, 10000, 100000}
+
+func buildTrie(words []string) *Trie {
+	t := NewTrie()
+	for _, w := range words {
+		t.Insert(w)
+	}
+	return t
+}
+
+// heapInUse returns the bytes of the heap still in use after a collection
+func heapInUse() int64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return int64(m.HeapAlloc)
+}
+
+// reportHeapSize reports how many bytes on the heap the value built by build
+// keeps alive, as the heap-B/index metric
+func reportHeapSize(b *testing.B, build func() interface{}) {
+	b.StopTimer()
+	before := heapInUse()
+	v := build()
+	after := heapInUse()
+	runtime.KeepAlive(v)
+	b.ReportMetric(float64(after-before), "heap-B/index")
+	b.StartTimer()
+}
+
+func BenchmarkTrieBuild(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		words := benchmarkWords(n)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buildTrie(words)
+			}
+			reportHeapSize(b, func() interface{} { return buildTrie(words) })
+		})
+	}
+}
+
+func BenchmarkSuffixArrayBuild(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		words := benchmarkWords(n)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				NewSuffixArray(words)
+			}
+			reportHeapSize(b, func() interface{} { return NewSuffixArray(words) })
+		})
+	}
+}
+
+func BenchmarkTriePrefixes(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		t := buildTrie(benchmarkWords(n))
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if node, ok := t.Find("FilePreview"); ok {
+					node.Prefixes()
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSuffixArrayLookup(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		sa := NewSuffixArray(benchmarkWords(n))
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				sa.Lookup("FilePreview")
+			}
+		})
+	}
+}
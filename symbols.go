@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+)
+
+// SymbolReferences is the response type for a find-references request. It
+// holds the stable ID of the symbol and every reference to it.
+type SymbolReferences struct {
+	Symbol     string    `json:"symbol"`
+	References []*Result `json:"references"`
+}
+
+// ReferencesBySymbol returns every reference to the symbol with the given ID.
+// Returns true if the symbol was found and false otherwise.
+func (x *Index) ReferencesBySymbol(id string) ([]Reference, bool) {
+	refs, ok := x.symbols[id]
+	return refs, ok
+}
+
+// SymbolAt returns the ID of the symbol referenced at the given position. If
+// col is 0, the first declaration on the line is used instead, so a symbol can
+// be looked up from the file and line of its declaration alone.
+func (x *Index) SymbolAt(file string, line, col int) (string, bool) {
+	if col > 0 {
+		ref, ok := x.referenceAt(file, line, col)
+		if !ok {
+			return "", false
+		}
+		return x.symbolOf(ref), true
+	}
+
+	for _, span := range x.idents[file] {
+		if span.Line == line && isDeclaration(span.ref) {
+			return x.symbolOf(span.ref), true
+		}
+	}
+	return "", false
+}
+
+// groupSymbols builds the per-symbol reference lists of the words. It must
+// run after scopeReferences since resolving declarations without type
+// information depends on the enclosing functions. Every symbol is named after
+// its word, so the symbols of a word are grouped again from scratch, with the
+// declarations of the word only collected once for all of its references.
+func (x *Index) groupSymbols(words []string) {
+	grouped := make(map[string]bool, len(words))
+	for _, word := range words {
+		if grouped[word] {
+			continue
+		}
+		grouped[word] = true
+
+		for _, id := range x.wordSymbols[word] {
+			delete(x.symbols, id)
+		}
+		var ids []string
+		var decls *declarations
+		for _, ref := range x.references[word] {
+			id := x.objectSymbol(ref)
+			if id == "" {
+				if decls == nil {
+					decls = x.declarationsOf(word)
+				}
+				id = x.declaredSymbol(ref, decls)
+			}
+			if _, ok := x.symbols[id]; !ok {
+				ids = append(ids, id)
+			}
+			x.symbols[id] = append(x.symbols[id], ref)
+		}
+		if len(ids) > 0 {
+			x.wordSymbols[word] = ids
+		} else {
+			delete(x.wordSymbols, word)
+		}
+	}
+}
+
+// symbolOf returns the ID of the symbol the Reference refers to
+func (x *Index) symbolOf(ref Reference) string {
+	if id := x.objectSymbol(ref); id != "" {
+		return id
+	}
+	return x.declaredSymbol(ref, x.declarationsOf(referenceName(ref)))
+}
+
+// declaredSymbol returns the ID of the symbol a Reference without type
+// information refers to, named by the position of its declaration
+func (x *Index) declaredSymbol(ref Reference, decls *declarations) string {
+	decl, ok := x.declarationIn(ref, decls)
+	if !ok {
+		// declared outside of the project, all we have is the name
+		return referenceName(ref)
+	}
+	loc := decl.GetLocation()
+	return fmt.Sprintf("%s:%d:%s", loc.File, loc.Line, referenceName(decl))
+}
+
+// objectSymbol returns the ID of the symbol for the object the Reference
+// resolved to with type information, or the one it was cached with. Returns
+// an empty string without either.
+func (x *Index) objectSymbol(ref Reference) string {
+	return x.objectSymbols[ref]
+}
+
+// recordSymbols finds the symbol IDs of the references of the files that
+// resolved to an object
+func (x *Index) recordSymbols(files []string) {
+	for _, file := range files {
+		for _, span := range x.idents[file] {
+			ref := span.ref
+			if f, ok := ref.(*Field); ok && f.Object != nil && f.Object.Pkg() != nil {
+				// fields have no scope to speak of, so name them after their
+				// struct
+				x.objectSymbols[ref] = fmt.Sprintf("%s.%s.%s", f.Object.Pkg().Path(), f.Struct, f.Name)
+			} else if obj := ref.GetObject(); obj != nil {
+				x.objectSymbols[ref] = x.objectID(obj)
+			}
+		}
+	}
+}
+
+// objectID returns a stable ID for a type-checked object. Package-level
+// objects and methods are named by their import path, eg.
+// "github.com/flapjack103/go-search.Index.Summary", while local objects are
+// named by the position of their declaration.
+func (x *Index) objectID(obj types.Object) string {
+	if obj.Pkg() == nil {
+		// predeclared, eg. len or error
+		return obj.Name()
+	}
+
+	pkgPath := obj.Pkg().Path()
+	if obj.Parent() == obj.Pkg().Scope() {
+		return fmt.Sprintf("%s.%s", pkgPath, obj.Name())
+	}
+	if fn, ok := obj.(*types.Func); ok {
+		if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+			return fmt.Sprintf("%s.%s.%s", pkgPath, receiverName(recv.Type()), obj.Name())
+		}
+	}
+
+	pos := x.fset.Position(obj.Pos())
+	if !pos.IsValid() {
+		return fmt.Sprintf("%s.%s", pkgPath, obj.Name())
+	}
+	return fmt.Sprintf("%s:%d:%d:%s", x.fileMgr.Rel(pos.Filename), pos.Line, pos.Column, obj.Name())
+}
+
+// receiverName returns the name of the type a method is declared on
+func receiverName(t types.Type) string {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	if named, ok := t.(*types.Named); ok {
+		return named.Obj().Name()
+	}
+	return t.String()
+}
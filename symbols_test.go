@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const symbolsSource = `package main
+
+import "bytes"
+
+type CallStack struct{}
+
+func (c *CallStack) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func main() {
+	c := &CallStack{}
+	c.Write(nil)
+	var b bytes.Buffer
+	b.Write(nil)
+}
+
+func count() {
+	n := 1
+	use(n)
+}
+
+func total() {
+	n := 2
+	use(n)
+}
+
+func use(n int) {}
+`
+
+func TestReferencesBySymbol(t *testing.T) {
+	root := writeProject(t, map[string]string{
+		"go.mod":  "module example.com/proj\n",
+		"main.go": symbolsSource,
+	})
+	defer os.RemoveAll(root)
+
+	fm := NewFileManager(root)
+	for _, typed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("typed=%t", typed), func(t *testing.T) {
+			assert := assert.New(t)
+			idx := BuildIndex(fm)
+			if typed {
+				idx = BuildTypedIndex(fm)
+			}
+			references := func(line, col int) []string {
+				id, ok := idx.SymbolAt("main.go", line, col)
+				if !ok {
+					return nil
+				}
+				refs, _ := idx.ReferencesBySymbol(id)
+				var within []string
+				for _, ref := range refs {
+					loc := ref.GetLocation()
+					within = append(within, fmt.Sprintf("%s:%d in %s", loc.File, loc.Line, loc.Within))
+				}
+				return within
+			}
+
+			// each n is a symbol of its own
+			assert.ElementsMatch([]string{
+				"main.go:19 in count (main.go:18)",
+				"main.go:20 in count (main.go:18)",
+			}, references(19, 0))
+			assert.ElementsMatch([]string{
+				"main.go:24 in total (main.go:23)",
+				"main.go:25 in total (main.go:23)",
+			}, references(25, 6))
+			assert.Nil(references(2, 1))
+			if !typed {
+				return
+			}
+
+			// the Write of CallStack, not the one of bytes.Buffer
+			id, _ := idx.SymbolAt("main.go", 7, 21)
+			assert.Equal("example.com/proj.CallStack.Write", id)
+			assert.ElementsMatch([]string{
+				"main.go:7 in CallStack.Write (main.go:7)",
+				"main.go:13 in main (main.go:11)",
+			}, references(7, 21))
+			id, _ = idx.SymbolAt("main.go", 15, 4)
+			assert.Equal("bytes.Buffer.Write", id)
+			assert.Equal([]string{"main.go:15 in main (main.go:11)"}, references(15, 4))
+		})
+	}
+}
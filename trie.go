@@ -1,5 +1,7 @@
 package main
 
+import "sync/atomic"
+
 // Terminator is used to mark the end of a word in the Trie
 const Terminator = '\\'
 
@@ -7,8 +9,13 @@ const Terminator = '\\'
 type Trie struct {
 	value    rune
 	children map[rune]*Trie
+	count    int   // times the word ending here was inserted, terminators only
+	gen      int64 // the clone the node belongs to, see clone
 }
 
+// trieGens counts the clones of every Trie, so each gets a generation of its own
+var trieGens int64
+
 // NewTrie creates an empty Trie
 func NewTrie() *Trie {
 	return &Trie{
@@ -16,6 +23,33 @@ func NewTrie() *Trie {
 	}
 }
 
+// child returns the child of the node for the rune, which the caller may
+// modify. A child shared with the Trie the node was cloned from is copied
+// first.
+func (t *Trie) child(r rune) (*Trie, bool) {
+	n, ok := t.children[r]
+	if ok && n.gen != t.gen {
+		n = n.copy(t.gen)
+		t.children[r] = n
+	}
+	return n, ok
+}
+
+// copy returns a copy of the node for the given generation, sharing its
+// children
+func (t *Trie) copy(gen int64) *Trie {
+	c := &Trie{
+		value:    t.value,
+		children: make(map[rune]*Trie, len(t.children)),
+		count:    t.count,
+		gen:      gen,
+	}
+	for r, n := range t.children {
+		c.children[r] = n
+	}
+	return c
+}
+
 // TrieFromIndex builds a prefix tree from the given index
 func TrieFromIndex(idx *Index) *Trie {
 	t := NewTrie()
@@ -30,7 +64,7 @@ func (t *Trie) Insert(word string) {
 	node := t
 	idx := -1
 	for i, c := range word {
-		n, ok := node.children[c]
+		n, ok := node.child(c)
 		if !ok {
 			idx = i
 			break
@@ -40,11 +74,77 @@ func (t *Trie) Insert(word string) {
 
 	if idx >= 0 {
 		for _, r := range word[idx:] {
-			node.children[r] = NewTrie()
-			node = node.children[r]
+			n := NewTrie()
+			n.gen = t.gen
+			node.children[r] = n
+			node = n
+		}
+	}
+	end, ok := node.child(Terminator)
+	if !ok {
+		end = NewTrie()
+		end.gen = t.gen
+		node.children[Terminator] = end
+	}
+	end.count++
+}
+
+// Delete removes one insertion of the word from the Trie. The word is only gone
+// once it has been deleted as many times as it was inserted, at which point the
+// nodes no longer leading to any word are pruned. Returns true if the word was
+// in the Trie and false otherwise.
+func (t *Trie) Delete(word string) bool {
+	runes := []rune(word)
+	path := []*Trie{t}
+	node := t
+	for _, c := range runes {
+		n, ok := node.child(c)
+		if !ok {
+			return false
+		}
+		path = append(path, n)
+		node = n
+	}
+
+	end, ok := node.child(Terminator)
+	if !ok {
+		return false
+	}
+	end.count--
+	if end.count > 0 {
+		return true
+	}
+	delete(node.children, Terminator)
+
+	for i := len(runes) - 1; i >= 0; i-- {
+		if len(path[i+1].children) > 0 {
+			break
 		}
+		delete(path[i].children, runes[i])
 	}
-	node.children[Terminator] = NewTrie()
+	return true
+}
+
+// Contains returns true if the word was inserted in the Trie, as opposed to
+// only being the prefix of another word
+func (t *Trie) Contains(word string) bool {
+	node, ok := t.Find(word)
+	if !ok {
+		return false
+	}
+	_, ok = node.children[Terminator]
+	return ok
+}
+
+// clone returns a copy of the Trie that can be modified without affecting the
+// original. The nodes are shared until Insert or Delete change them in either,
+// at which point the nodes on the path to the word are copied, so a clone
+// costs as much as the words it changes.
+func (t *Trie) clone() *Trie {
+	c := t.copy(atomic.AddInt64(&trieGens, 1))
+	// the original is cloned on write too, now that it shares its nodes
+	t.gen = atomic.AddInt64(&trieGens, 1)
+	return c
 }
 
 // Find the word in the prefix tree, returns the node and true if it exists
@@ -80,3 +180,65 @@ func buildWords(prefix string, t *Trie) []string {
 
 	return words
 }
+
+// FuzzyFind returns the words of the Trie that start with a prefix at most
+// maxDist edits away from the given word, mapped to the distance of their
+// closest prefix. An edit inserts, deletes or substitutes a letter, or swaps
+// two adjacent ones (the optimal string alignment variant of the
+// Damerau-Levenshtein distance).
+func (t *Trie) FuzzyFind(word string, maxDist int) map[string]int {
+	query := []rune(word)
+	row := make([]int, len(query)+1)
+	for j := range row {
+		row[j] = j
+	}
+
+	matches := make(map[string]int)
+	t.fuzzyWalk(query, nil, 0, nil, row, row[len(query)], maxDist, matches)
+	return matches
+}
+
+// fuzzyWalk computes the edit distance rows of the query against the prefixes
+// below the node, where prev is the row for the prefix itself and pp the row
+// before it. best is the smallest distance of the query to any prefix so far.
+func (t *Trie) fuzzyWalk(query, prefix []rune, last rune, pp, prev []int, best, maxDist int, matches map[string]int) {
+	for r, n := range t.children {
+		if r == Terminator {
+			if best <= maxDist {
+				matches[string(prefix)] = best
+			}
+			continue
+		}
+
+		row := make([]int, len(query)+1)
+		row[0] = prev[0] + 1
+		rowMin := row[0]
+		for j := 1; j <= len(query); j++ {
+			cost := 1
+			if query[j-1] == r {
+				cost = 0
+			}
+			row[j] = minInt(prev[j]+1, row[j-1]+1, prev[j-1]+cost)
+			if pp != nil && j > 1 && query[j-1] == last && query[j-2] == r {
+				row[j] = minInt(row[j], pp[j-2]+1)
+			}
+			rowMin = minInt(rowMin, row[j])
+		}
+
+		nextBest := minInt(best, row[len(query)])
+		// no prefix below can get any closer
+		if nextBest > maxDist && rowMin > maxDist && minInt(prev[0], prev[1:]...) > maxDist {
+			continue
+		}
+		n.fuzzyWalk(query, append(prefix[:len(prefix):len(prefix)], r), r, prev, row, nextBest, maxDist, matches)
+	}
+}
+
+func minInt(n int, rest ...int) int {
+	for _, m := range rest {
+		if m < n {
+			n = m
+		}
+	}
+	return n
+}
@@ -79,3 +79,117 @@ func TestTrieFind(t *testing.T) {
 	_, ok = tri.Find("tagz")
 	assert.False(ok)
 }
+
+func TestTrieDelete(t *testing.T) {
+	assert := assert.New(t)
+	words := []string{
+		"t",
+		"tag",
+		"tags",
+		"test",
+		"string",
+		"stripe",
+		"set",
+	}
+
+	tri := NewTrie()
+	for _, w := range words {
+		tri.Insert(w)
+	}
+
+	// a word that is only a prefix can't be deleted
+	assert.False(tri.Delete("ta"))
+	assert.False(tri.Delete("tagz"))
+
+	// deleting a word keeps its prefixes and extensions
+	assert.True(tri.Delete("tag"))
+	assert.False(tri.Contains("tag"))
+	assert.True(tri.Contains("tags"))
+	assert.True(tri.Contains("t"))
+
+	// deleting the last word down a branch prunes the branch
+	assert.True(tri.Delete("set"))
+	assert.True(tri.Delete("string"))
+	assert.True(tri.Delete("stripe"))
+	assert.NotContains(tri.children, 's')
+
+	n, ok := tri.Find("t")
+	assert.True(ok)
+	twords := n.Prefixes()
+	sort.Strings(twords)
+	assert.Equal([]string{"", "ags", "est"}, twords)
+
+	assert.False(tri.Delete("set"))
+}
+
+func TestTrieDeleteCounts(t *testing.T) {
+	assert := assert.New(t)
+
+	tri := NewTrie()
+	tri.Insert("tag")
+	tri.Insert("tag")
+	tri.Insert("tags")
+
+	// the word stays until it is deleted as often as it was inserted
+	assert.True(tri.Delete("tag"))
+	assert.True(tri.Contains("tag"))
+	assert.True(tri.Delete("tag"))
+	assert.False(tri.Contains("tag"))
+	assert.False(tri.Delete("tag"))
+
+	assert.True(tri.Delete("tags"))
+	assert.Empty(tri.children)
+	assert.Empty(tri.Prefixes())
+}
+
+func TestTrieFuzzyFind(t *testing.T) {
+	assert := assert.New(t)
+	tri := NewTrie()
+	for _, w := range []string{"BuildCallStack", "BuildIndex", "Build", "Query", "Querier"} {
+		tri.Insert(w)
+	}
+
+	// a missing letter
+	assert.Equal(map[string]int{"BuildCallStack": 1}, tri.FuzzyFind("BuildCalStack", 1))
+	// swapped letters count as a single edit
+	assert.Equal(map[string]int{"BuildIndex": 1}, tri.FuzzyFind("BuildInedx", 1))
+	// words match by their closest prefix
+	assert.Equal(map[string]int{"Query": 0, "Querier": 0}, tri.FuzzyFind("Que", 1))
+	assert.Equal(map[string]int{"Query": 1, "Querier": 1}, tri.FuzzyFind("Qeu", 1))
+	assert.Empty(tri.FuzzyFind("Qeu", 0))
+	assert.Len(tri.FuzzyFind("", 0), 5)
+}
+
+func TestTrieClone(t *testing.T) {
+	assert := assert.New(t)
+	tri := NewTrie()
+	for _, w := range []string{"tag", "tags", "test", "set"} {
+		tri.Insert(w)
+	}
+
+	c := tri.clone()
+	c.Insert("team")
+	c.Delete("tags")
+	c.Delete("set")
+	words := c.Prefixes()
+	sort.Strings(words)
+	assert.Equal([]string{"tag", "team", "test"}, words)
+
+	// the original keeps its words, and changing it leaves the clone alone
+	words = tri.Prefixes()
+	sort.Strings(words)
+	assert.Equal([]string{"set", "tag", "tags", "test"}, words)
+	tri.Delete("test")
+	assert.False(tri.Contains("test"))
+	assert.True(c.Contains("test"))
+
+	// a clone of the clone shares the nodes of both
+	cc := c.clone()
+	cc.Insert("test")
+	assert.True(cc.Delete("test"))
+	assert.True(cc.Contains("test"))
+	assert.True(c.Contains("test"))
+	assert.True(cc.Delete("test"))
+	assert.False(cc.Contains("test"))
+	assert.True(c.Contains("test"))
+}
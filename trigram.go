@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
+
+// TrigramIndex maps every sequence of three bytes in the source of the project
+// to the files containing it, so a regular expression is only run against the
+// files that contain the literal text it needs to match, the way codesearch
+// and Zoekt do. The source is folded to lower case, which lets the same index
+// serve case insensitive expressions.
+type TrigramIndex struct {
+	files    map[string][]string // trigrams of each file
+	postings map[string][]string // sorted files containing each trigram
+}
+
+// NewTrigramIndex creates an empty TrigramIndex
+func NewTrigramIndex() *TrigramIndex {
+	return &TrigramIndex{
+		files:    make(map[string][]string),
+		postings: make(map[string][]string),
+	}
+}
+
+// TrigramIndexFromFiles builds the TrigramIndex of the given files
+func TrigramIndexFromFiles(files []string) *TrigramIndex {
+	t := NewTrigramIndex()
+	for _, file := range files {
+		if err := t.AddFile(file); err != nil {
+			fmt.Printf("could not index the source of %s: %v\n", file, err)
+		}
+	}
+	return t
+}
+
+// AddFile reads the file and indexes its source, replacing what was indexed
+// of it before
+func (t *TrigramIndex) AddFile(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	t.Add(file, data)
+	return nil
+}
+
+// Add indexes the source of the file, replacing what was indexed of it before
+func (t *TrigramIndex) Add(file string, data []byte) {
+	t.Remove(file)
+
+	data = bytes.ToLower(data)
+	seen := make(map[string]bool)
+	var trigrams []string
+	for i := 0; i+3 <= len(data); i++ {
+		tri := string(data[i : i+3])
+		if !seen[tri] {
+			seen[tri] = true
+			trigrams = append(trigrams, tri)
+		}
+	}
+
+	t.files[file] = trigrams
+	for _, tri := range trigrams {
+		// the postings may be shared with a clone, so they are copied
+		files := t.postings[tri]
+		i := sort.SearchStrings(files, file)
+		updated := make([]string, 0, len(files)+1)
+		updated = append(updated, files[:i]...)
+		updated = append(updated, file)
+		t.postings[tri] = append(updated, files[i:]...)
+	}
+}
+
+// Remove drops the file from the index
+func (t *TrigramIndex) Remove(file string) {
+	for _, tri := range t.files[file] {
+		files := t.postings[tri]
+		i := sort.SearchStrings(files, file)
+		if i == len(files) || files[i] != file {
+			continue
+		}
+		if len(files) == 1 {
+			delete(t.postings, tri)
+			continue
+		}
+		updated := make([]string, 0, len(files)-1)
+		updated = append(updated, files[:i]...)
+		t.postings[tri] = append(updated, files[i+1:]...)
+	}
+	delete(t.files, file)
+}
+
+// clone returns a copy of the index. The trigrams and postings are never
+// modified in place, so they are shared.
+func (t *TrigramIndex) clone() *TrigramIndex {
+	c := &TrigramIndex{
+		files:    make(map[string][]string, len(t.files)),
+		postings: make(map[string][]string, len(t.postings)),
+	}
+	for file, trigrams := range t.files {
+		c.files[file] = trigrams
+	}
+	for tri, files := range t.postings {
+		c.postings[tri] = files
+	}
+	return c
+}
+
+// Candidates returns the files that may contain a match of the regular
+// expression, in sorted order. That is every file if the expression doesn't
+// need any literal text of three bytes or more to match.
+func (t *TrigramIndex) Candidates(re *syntax.Regexp) []string {
+	var files []string
+	if set := t.eval(requiredTrigrams(re.Simplify())); set != nil {
+		for file := range set {
+			files = append(files, file)
+		}
+	} else {
+		for file := range t.files {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// eval returns the files matching the query, or nil if it matches every file
+func (t *TrigramIndex) eval(q *trigramQuery) map[string]bool {
+	if q == nil {
+		return nil
+	}
+
+	var set map[string]bool
+	if q.or {
+		set = make(map[string]bool)
+		for _, sub := range q.subs {
+			for file := range t.eval(sub) {
+				set[file] = true
+			}
+		}
+		return set
+	}
+
+	intersect := func(files map[string]bool) {
+		if set == nil {
+			set = files
+			return
+		}
+		for file := range set {
+			if !files[file] {
+				delete(set, file)
+			}
+		}
+	}
+	for _, tri := range q.trigrams {
+		files := make(map[string]bool)
+		for _, file := range t.postings[tri] {
+			files[file] = true
+		}
+		intersect(files)
+	}
+	for _, sub := range q.subs {
+		if files := t.eval(sub); files != nil {
+			intersect(files)
+		}
+	}
+	if set == nil {
+		set = make(map[string]bool)
+	}
+	return set
+}
+
+// trigramQuery is what a file must contain to possibly match a regular
+// expression: all of the trigrams and sub queries, or with or set, any of the
+// sub queries. A nil query matches every file.
+type trigramQuery struct {
+	or       bool
+	trigrams []string
+	subs     []*trigramQuery
+}
+
+func andQuery(a, b *trigramQuery) *trigramQuery {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	return &trigramQuery{subs: []*trigramQuery{a, b}}
+}
+
+func orQuery(a, b *trigramQuery) *trigramQuery {
+	if a == nil || b == nil {
+		return nil
+	}
+	return &trigramQuery{or: true, subs: []*trigramQuery{a, b}}
+}
+
+func literalQuery(lit []rune) *trigramQuery {
+	s := strings.ToLower(string(lit))
+	if len(s) < 3 {
+		return nil
+	}
+	q := &trigramQuery{}
+	for i := 0; i+3 <= len(s); i++ {
+		q.trigrams = append(q.trigrams, s[i:i+3])
+	}
+	return q
+}
+
+// requiredTrigrams returns the query for the literal text a match of the
+// regular expression must contain. Anything that may match without literal
+// text, like a character class or a repetition that can be empty, requires
+// nothing.
+func requiredTrigrams(re *syntax.Regexp) *trigramQuery {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return literalQuery(re.Rune)
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredTrigrams(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			return requiredTrigrams(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		// adjacent literals make for a longer run of literal text
+		var q *trigramQuery
+		var run []rune
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpLiteral {
+				run = append(run, sub.Rune...)
+				continue
+			}
+			q = andQuery(q, literalQuery(run))
+			run = nil
+			q = andQuery(q, requiredTrigrams(sub))
+		}
+		return andQuery(q, literalQuery(run))
+	case syntax.OpAlternate:
+		q := requiredTrigrams(re.Sub[0])
+		for _, sub := range re.Sub[1:] {
+			q = orQuery(q, requiredTrigrams(sub))
+		}
+		return q
+	}
+	return nil
+}
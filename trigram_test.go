@@ -0,0 +1,35 @@
+package main
+
+import (
+	"regexp/syntax"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrigramIndexCandidates(t *testing.T) {
+	assert := assert.New(t)
+	idx := NewTrigramIndex()
+	idx.Add("a.go", []byte("func HandleFunc() {} // TODO"))
+	idx.Add("b.go", []byte("func Handle() {} // XXX"))
+	idx.Add("c.go", []byte("var x = 1"))
+
+	candidates := func(expr string) []string {
+		re, err := syntax.Parse(expr, syntax.Perl)
+		assert.NoError(err)
+		return idx.Candidates(re)
+	}
+
+	assert.Equal([]string{"a.go"}, candidates(`Handle.*Func\(`))
+	assert.Equal([]string{"a.go", "b.go"}, candidates("TODO|XXX"))
+	assert.Equal([]string{"a.go", "b.go"}, candidates("(?i)handle"))
+	assert.Empty(candidates("Missing"))
+	// nothing to narrow the search down with
+	assert.Equal([]string{"a.go", "b.go", "c.go"}, candidates("x|TODO"))
+	assert.Equal([]string{"a.go", "b.go", "c.go"}, candidates("[a-z]+"))
+
+	idx.Add("b.go", []byte("func Serve() {}"))
+	assert.Equal([]string{"a.go"}, candidates("TODO|XXX"))
+	idx.Remove("a.go")
+	assert.Empty(candidates("TODO|XXX"))
+}
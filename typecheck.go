@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// loadMode is what go/packages loads of every package to type-check it
+const loadMode = packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles |
+	packages.NeedImports | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedSyntax
+
+// BuildTypedIndex constructs the Index like BuildIndex, but loads every package
+// in the project and type-checks it with go/types before walking the ASTs. Each
+// Reference in the resulting Index points at the object it resolves to, so
+// identically named symbols in different scopes or packages can be told apart.
+func BuildTypedIndex(fm *FileManager) *Index {
+	idx := newIndex(fm)
+	idx.typed = true
+	idx.indexTypedFiles(fm.files)
+	idx.finish()
+	return idx
+}
+
+// indexTypedFiles loads the packages the given files belong to with
+// go/packages, the way the go command builds them, and walks just those files
+// with the type information of their package. Files that are not part of any
+// package the go command can build, eg. because of build constraints, are
+// indexed without type information.
+func (x *Index) indexTypedFiles(files []string) {
+	if len(files) == 0 {
+		return
+	}
+
+	wanted := make(map[string]string, len(files))
+	var patterns []string
+	for _, f := range files {
+		relPath := x.fileMgr.Rel(f)
+		wanted[relPath] = f
+		pattern := "./" + filepath.ToSlash(filepath.Dir(relPath))
+		if pattern == "./." {
+			pattern = "."
+		}
+		if !hasString(patterns, pattern) {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	pkgs, err := packages.Load(x.loadConfig(), patterns...)
+	if err != nil {
+		fmt.Printf("could not load packages: %v\n", err)
+		x.indexFiles(files)
+		return
+	}
+
+	walked := make(map[string]bool, len(files))
+	for _, pkg := range sortPackages(pkgs) {
+		if len(pkg.Errors) > 0 {
+			fmt.Printf("type errors in %s: %v\n", pkg.PkgPath, pkg.Errors[0])
+		}
+		x.info = pkg.TypesInfo
+		for _, f := range pkg.Syntax {
+			relPath := x.fileMgr.Rel(x.fset.Position(f.Package).Filename)
+			if _, ok := wanted[relPath]; ok && !walked[relPath] {
+				walked[relPath] = true
+				ast.Walk(x, f)
+			}
+		}
+	}
+	x.info = nil
+
+	var rest []string
+	for _, f := range files {
+		if !walked[x.fileMgr.Rel(f)] {
+			rest = append(rest, f)
+		}
+	}
+	x.indexFiles(rest)
+}
+
+// loadConfig returns the go/packages configuration for loading the packages of
+// the project, from its root directory. The project is loaded as a module if
+// it has a go.mod, and the way GOPATH mode would build it otherwise.
+func (x *Index) loadConfig() *packages.Config {
+	cfg := &packages.Config{
+		Mode:  loadMode,
+		Dir:   x.fileMgr.root,
+		Fset:  x.fset,
+		Tests: true,
+		ParseFile: func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+			return parser.ParseFile(fset, filename, src, parser.AllErrors|parser.ParseComments)
+		},
+	}
+	if _, err := os.Stat(filepath.Join(x.fileMgr.root, "go.mod")); err == nil {
+		cfg.Env = append(os.Environ(), "GO111MODULE=on")
+	} else {
+		cfg.Env = append(os.Environ(), "GO111MODULE=off")
+	}
+	return cfg
+}
+
+// sortPackages returns the packages in a stable order, with the packages as
+// they are built first and their test variants after, so a file that is in
+// both is walked as part of the package itself. The test main packages the go
+// command generates are left out.
+func sortPackages(pkgs []*packages.Package) []*packages.Package {
+	var sorted []*packages.Package
+	for _, pkg := range pkgs {
+		if !strings.HasSuffix(pkg.ID, ".test") {
+			sorted = append(sorted, pkg)
+		}
+	}
+	isTest := func(pkg *packages.Package) bool {
+		return strings.Contains(pkg.ID, " [")
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if isTest(sorted[i]) != isTest(sorted[j]) {
+			return !isTest(sorted[i])
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
+}
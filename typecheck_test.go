@@ -0,0 +1,163 @@
+package main
+
+import (
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var typedProject = map[string]string{
+	"go.mod": "module example.com/proj\n",
+	"main.go": `package main
+
+import "example.com/proj/util"
+
+func main() {
+	n := util.Count()
+	_ = n
+}
+`,
+	"util/util.go": `package util
+
+// Count counts
+func Count() int {
+	n := 1
+	return n
+}
+`,
+	"util/util_test.go": `package util
+
+import "testing"
+
+func TestCount(t *testing.T) {
+	if Count() != 1 {
+		t.Fail()
+	}
+}
+`,
+	// not part of any package the go command builds
+	"util/gen.go": `//go:build ignore
+
+package main
+
+func generate() {}
+`,
+}
+
+// symbolsOf returns the symbol IDs of every reference to the word
+func symbolsOf(idx *Index, word string) []string {
+	var ids []string
+	for _, ref := range idx.references[word] {
+		if id := idx.symbolOf(ref); !hasString(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func TestBuildTypedIndex(t *testing.T) {
+	assert := assert.New(t)
+	root := writeProject(t, typedProject)
+	defer os.RemoveAll(root)
+
+	idx := BuildTypedIndex(NewFileManager(root))
+	// the call in main, the declaration and the call in the test resolve to
+	// the same function
+	assert.Len(idx.references["Count"], 3)
+	for _, ref := range idx.references["Count"] {
+		assert.NotNil(ref.GetObject())
+	}
+	assert.Equal([]string{"example.com/proj/util.Count"}, symbolsOf(idx, "Count"))
+	// the two locals named n are different variables
+	assert.Len(symbolsOf(idx, "n"), 2)
+	// files the go command leaves out are still indexed, without types
+	if assert.Len(idx.references["generate"], 1) {
+		assert.Nil(idx.references["generate"][0].GetObject())
+	}
+}
+
+func TestBuildTypedIndexGOPATH(t *testing.T) {
+	assert := assert.New(t)
+	gopath, err := ioutil.TempDir("", "gopath")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+	defer os.Setenv("GOPATH", os.Getenv("GOPATH"))
+	defer func(old string) { build.Default.GOPATH = old }(build.Default.GOPATH)
+	os.Setenv("GOPATH", gopath)
+	build.Default.GOPATH = gopath
+
+	// without its go.mod, the project is found by its path in the GOPATH
+	root := filepath.Join(gopath, "src", "example.com", "proj")
+	for name, src := range typedProject {
+		if name == "go.mod" {
+			continue
+		}
+		file := filepath.Join(root, filepath.FromSlash(name))
+		assert.NoError(os.MkdirAll(filepath.Dir(file), 0755))
+		assert.NoError(ioutil.WriteFile(file, []byte(src), 0644))
+	}
+
+	idx := BuildTypedIndex(NewFileManager(root))
+	assert.Len(idx.references["Count"], 3)
+	assert.Equal([]string{"example.com/proj/util.Count"}, symbolsOf(idx, "Count"))
+}
+
+const usesSource = `package main
+
+const limit = 10
+
+type Store struct{ items []int }
+
+func (s *Store) Get() int { return len(s.items) }
+
+func main() {
+	x := 1
+	var z int
+	z = x
+	y := x + limit
+	s := &Store{items: []int{x, y}}
+	fn := s.Get
+	use(s.Get(), z, fn)
+}
+
+func use(args ...interface{}) int {
+	return len(args)
+}
+`
+
+func TestBuildTypedIndexUses(t *testing.T) {
+	assert := assert.New(t)
+	root := writeProject(t, map[string]string{
+		"go.mod":  "module example.com/proj\n",
+		"main.go": usesSource,
+	})
+	defer os.RemoveAll(root)
+
+	idx := BuildTypedIndex(NewFileManager(root))
+	lines := func(word string) []int {
+		var lines []int
+		for _, ref := range idx.references[word] {
+			assert.NotNil(ref.GetObject(), "%s at %s", word, ref.GetLocation())
+			lines = append(lines, ref.GetLocation().Line)
+		}
+		sort.Ints(lines)
+		return lines
+	}
+	// the declaration, the assignment, the operand and the literal element
+	assert.Equal([]int{10, 12, 13, 14}, lines("x"))
+	assert.Equal([]int{11, 12, 16}, lines("z"))
+	assert.Equal([]int{3, 13}, lines("limit"))
+	// the receiver, the declaration and both uses of s in main
+	assert.Equal([]int{7, 7, 14, 15, 16}, lines("s"))
+	assert.Equal([]int{7, 15, 16}, lines("Get"))
+	assert.Len(symbolsOf(idx, "x"), 1)
+	// the receiver is not the s of main
+	assert.Len(symbolsOf(idx, "s"), 2)
+}
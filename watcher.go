@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"time"
+)
+
+// DefaultWatchInterval is how often the Watcher polls the project for changes
+const DefaultWatchInterval = 2 * time.Second
+
+// Watcher polls the project for .go files that were added, changed or removed.
+// It compares the modification time and size of every file, which only takes
+// a walk of the directory tree and avoids depending on filesystem
+// notifications.
+type Watcher struct {
+	fileMgr  *FileManager
+	interval time.Duration
+	stamps   map[string]fileStamp
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewWatcher inits a Watcher for the project of the FileManager. Changes are
+// reported relative to the state of the files when it is created, so it should
+// be created before the project is indexed.
+func NewWatcher(fm *FileManager, interval time.Duration) *Watcher {
+	w := &Watcher{
+		fileMgr:  fm,
+		interval: interval,
+	}
+	w.stamps = w.scan()
+	return w
+}
+
+// Watch polls the project forever. When something changed, onChange is called
+// with every file now in the project, the files that were added or modified,
+// and the files that were removed.
+func (w *Watcher) Watch(onChange func(files, changed, removed []string)) {
+	for range time.Tick(w.interval) {
+		files, changed, removed := w.poll()
+		if len(changed) > 0 || len(removed) > 0 {
+			onChange(files, changed, removed)
+		}
+	}
+}
+
+func (w *Watcher) poll() (files, changed, removed []string) {
+	stamps := w.scan()
+	for path, stamp := range stamps {
+		files = append(files, path)
+		if old, ok := w.stamps[path]; !ok || old != stamp {
+			changed = append(changed, path)
+		}
+	}
+	for path := range w.stamps {
+		if _, ok := stamps[path]; !ok {
+			removed = append(removed, path)
+		}
+	}
+	w.stamps = stamps
+
+	sort.Strings(files)
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return files, changed, removed
+}
+
+func (w *Watcher) scan() map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+	w.fileMgr.walkFiles(func(path string, info os.FileInfo) {
+		stamps[path] = fileStamp{info.ModTime(), info.Size()}
+	})
+	return stamps
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatcherPoll(t *testing.T) {
+	assert := assert.New(t)
+	root := writeProject(t, map[string]string{
+		"a.go":      "package main\n",
+		"b.go":      "package main\n",
+		"util/c.go": "package util\n",
+		"README.md": "not go\n",
+	})
+	defer os.RemoveAll(root)
+	path := func(name string) string {
+		return filepath.Join(root, filepath.FromSlash(name))
+	}
+
+	w := NewWatcher(NewFileManager(root), time.Second)
+	files, changed, removed := w.poll()
+	assert.Equal([]string{path("a.go"), path("b.go"), path("util/c.go")}, files)
+	assert.Empty(changed)
+	assert.Empty(removed)
+
+	// touch a file without changing its size, add one and delete another
+	later := time.Now().Add(time.Hour)
+	assert.NoError(os.Chtimes(path("a.go"), later, later))
+	assert.NoError(ioutil.WriteFile(path("util/d.go"), []byte("package util\n"), 0644))
+	assert.NoError(os.Remove(path("b.go")))
+	assert.NoError(ioutil.WriteFile(path("README.md"), []byte("still not go\n"), 0644))
+
+	files, changed, removed = w.poll()
+	assert.Equal([]string{path("a.go"), path("util/c.go"), path("util/d.go")}, files)
+	assert.Equal([]string{path("a.go"), path("util/d.go")}, changed)
+	assert.Equal([]string{path("b.go")}, removed)
+
+	// changes are only reported once
+	files, changed, removed = w.poll()
+	assert.Len(files, 3)
+	assert.Empty(changed)
+	assert.Empty(removed)
+}